@@ -1,62 +1,156 @@
 package auth
 
 import (
-	"crypto/rsa"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
 	"io/ioutil"
-	"sync"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var (
-	signKey   *rsa.PrivateKey
-	verifyKey *rsa.PublicKey
-	once      sync.Once
-)
+// HMAC secrets shorter than this are rejected (RFC 7518 section 3.2)
+const minHMACSecretLength = 32
 
-// We left them private (Only can be used by this package)
-// so that the other packages can not manipulate them
+// keyType describes the files of a kind of key and how to parse them.
+//
+// Asymmetric keys are a pair of PEM files, the private one is optional.
+// HMAC keys are a single file with the shared secret, so publicExt is empty.
+type keyType struct {
+	alg          string
+	method       jwt.SigningMethod
+	privateExt   string
+	publicExt    string
+	parsePrivate func([]byte) (crypto.PrivateKey, error)
+	parsePublic  func([]byte) (crypto.PublicKey, error)
+}
 
-// Singleton that assign a value to this vars only once (EXPORTED)
-func LoadCertificates(privateFile, publicFile string) error {
-	var err error
+// Supported kinds of keys
+var keyTypes = []keyType{
+	{
+		alg:        "RS256",
+		method:     jwt.SigningMethodRS256,
+		privateExt: ".rsa",
+		publicExt:  ".rsa.pub",
+		parsePrivate: func(b []byte) (crypto.PrivateKey, error) {
+			return jwt.ParseRSAPrivateKeyFromPEM(b)
+		},
+		parsePublic: func(b []byte) (crypto.PublicKey, error) {
+			return jwt.ParseRSAPublicKeyFromPEM(b)
+		},
+	},
+	{
+		alg:        "ES256",
+		method:     jwt.SigningMethodES256,
+		privateExt: ".ec",
+		publicExt:  ".ec.pub",
+		parsePrivate: func(b []byte) (crypto.PrivateKey, error) {
+			key, err := jwt.ParseECPrivateKeyFromPEM(b)
+			if err != nil {
+				return nil, err
+			}
+			return key, checkP256(&key.PublicKey)
+		},
+		parsePublic: func(b []byte) (crypto.PublicKey, error) {
+			key, err := jwt.ParseECPublicKeyFromPEM(b)
+			if err != nil {
+				return nil, err
+			}
+			return key, checkP256(key)
+		},
+	},
+	{
+		alg:          "EdDSA",
+		method:       jwt.SigningMethodEdDSA,
+		privateExt:   ".ed25519",
+		publicExt:    ".ed25519.pub",
+		parsePrivate: jwt.ParseEdPrivateKeyFromPEM,
+		parsePublic:  jwt.ParseEdPublicKeyFromPEM,
+	},
+	{
+		alg:        "HS256",
+		method:     jwt.SigningMethodHS256,
+		privateExt: ".hmac",
+		parsePrivate: func(b []byte) (crypto.PrivateKey, error) {
+			secret := bytes.TrimSpace(b)
+			if len(secret) < minHMACSecretLength {
+				return nil, errors.New("HMAC secret must have at least 32 bytes")
+			}
+			return secret, nil
+		},
+	},
+}
 
-	once.Do(func() {
-		err = loadCertificates(privateFile, publicFile)
-	})
+// Returns the kind of key that uses the algorithm
+func keyTypeByAlg(alg string) (keyType, bool) {
+	for _, kt := range keyTypes {
+		if kt.alg == alg {
+			return kt, true
+		}
+	}
 
-	return err
+	return keyType{}, false
 }
 
-// Loads the certicates and send them to be parsed
-func loadCertificates(privateFile, publicFile string) error {
+// Loads a pair of certificates and send them to be parsed.
+//
+// The private file is optional, without it the key is only used to verify.
+// HMAC keys don't have a public file, the secret is used for both.
+func loadCertificates(kt keyType, privateFile, publicFile string) (crypto.PrivateKey, crypto.PublicKey, error) {
 	// It's cool to use ioutil.ReadFile beacause it reads the file and
 	// close it after it got the value.
 	// If we use os.Open, we should close manually the document.
-	privateBytes, err := ioutil.ReadFile(privateFile)
-	if err != nil {
-		return err
+	var privateBytes, publicBytes []byte
+	var err error
+
+	if privateFile != "" {
+		privateBytes, err = ioutil.ReadFile(privateFile)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	publicBytes, err := ioutil.ReadFile(publicFile)
-	if err != nil {
-		return err
+	if publicFile != "" {
+		publicBytes, err = ioutil.ReadFile(publicFile)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return parseRSA(privateBytes, publicBytes)
+	return parseKeys(kt, privateBytes, publicBytes)
 }
 
 // Parces the certicates
-func parseRSA(privateBytes, publicBytes []byte) error {
+func parseKeys(kt keyType, privateBytes, publicBytes []byte) (crypto.PrivateKey, crypto.PublicKey, error) {
+	var signKey crypto.PrivateKey
 	var err error
-	signKey, err = jwt.ParseRSAPrivateKeyFromPEM(privateBytes)
-	if err != nil {
-		return err
+
+	if privateBytes != nil {
+		signKey, err = kt.parsePrivate(privateBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Symmetric keys verify with the same secret
+	if kt.parsePublic == nil {
+		return signKey, signKey, nil
 	}
 
-	verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(publicBytes)
+	verifyKey, err := kt.parsePublic(publicBytes)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+
+	return signKey, verifyKey, nil
+}
+
+// ES256 is only defined for the P-256 curve
+func checkP256(key *ecdsa.PublicKey) error {
+	if key.Curve != elliptic.P256() {
+		return errors.New("ES256 keys must use the P-256 curve")
 	}
 
 	return nil
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// Private type so that no other package can collide with our key
+type contextKey string
+
+const claimKey contextKey = "claim"
+
+// Returns a copy of the context that carries the claim of the validated token.
+func NewContext(ctx context.Context, claim models.Claim) context.Context {
+	return context.WithValue(ctx, claimKey, claim)
+}
+
+// Returns the claim stored by NewContext, ok is false if there is none.
+func ClaimFromContext(ctx context.Context) (models.Claim, bool) {
+	claim, ok := ctx.Value(claimKey).(models.Claim)
+	return claim, ok
+}
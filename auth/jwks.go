@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+)
+
+// JWK is the json representation of a public key (RFC 7517).
+//
+// RSA keys use n and e, EC keys use crv, x and y and Ed25519 keys (OKP)
+// use crv and x.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is the set of public keys that other services use to verify our tokens.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Returns the public keys of the ring as a JWKS. HMAC secrets are never published.
+func (k *KeyRing) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+
+	for _, key := range k.Keys() {
+		jwk := JWK{Kid: key.Kid, Use: "sig", Alg: key.Algorithm}
+
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeBase64(pub.N.Bytes())
+			jwk.E = encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			// The coordinates have a fixed size of 32 bytes on P-256
+			jwk.Kty = "EC"
+			jwk.Crv = "P-256"
+			jwk.X = encodeBase64(pub.X.FillBytes(make([]byte, 32)))
+			jwk.Y = encodeBase64(pub.Y.FillBytes(make([]byte, 32)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encodeBase64(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	// Keep the output stable
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Publishes the public keys of the key ring
+//
+// It's served at /.well-known/jwks.json so that other services can verify the tokens.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if keys == nil {
+		problem.Write(w, r, problem.Wrap(ErrKeysNotLoaded, http.StatusServiceUnavailable, problem.CodeUnavailable, "The keys have not been loaded"))
+		return
+	}
+
+	json, _ := json.Marshal(keys.JWKS())
+
+	// The keys change rarely, let the clients cache them for a while
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	handler.SendResponse(w, http.StatusOK, json, "")
+}
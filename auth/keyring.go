@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnknownKey    = errors.New("Unknown signing key")
+	ErrKeysNotLoaded = errors.New("The keys have not been loaded")
+)
+
+// Key is one of the keys of the KeyRing.
+type Key struct {
+	Kid        string
+	Algorithm  string // The only algorithm accepted for this key
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey // nil if the key can only verify
+	PublicKey  crypto.PublicKey  // The secret itself for HMAC keys
+}
+
+// Returns true if the key is a shared secret, so it must not be published.
+func (k *Key) Symmetric() bool {
+	return k.Algorithm == "HS256"
+}
+
+// KeyRing holds every key that can be used to verify a token and the one
+// that is used to sign the new ones.
+//
+// The keys are loaded from a directory, the name of the files (Without the
+// extension) is used as the kid and the extension tells the kind of key:
+//   - RS256: "app.rsa" and "app.rsa.pub"
+//   - ES256: "app.ec" and "app.ec.pub" (P-256 curve)
+//   - EdDSA: "app.ed25519" and "app.ed25519.pub"
+//   - HS256: "app.hmac" (The shared secret, at least 32 bytes)
+//
+// In order to rotate the keys, add the new pair to the directory, make it
+// active and reload. Once the tokens signed with the old key are expired,
+// remove its files (Retire it) and reload again.
+type KeyRing struct {
+	dir       string
+	activeKid string // If empty, the newest private key is the active one
+	algorithm string // If not empty, only keys of this algorithm can sign
+
+	mu     sync.RWMutex
+	keys   map[string]*Key
+	active *Key
+}
+
+// Returns a KeyRing for the directory, it does not load the keys (See Load).
+func NewKeyRing(dir, activeKid, algorithm string) (*KeyRing, error) {
+	if _, ok := keyTypeByAlg(algorithm); algorithm != "" && !ok {
+		return nil, fmt.Errorf("Unsupported signing algorithm %s", algorithm)
+	}
+
+	return &KeyRing{
+		dir:       dir,
+		activeKid: activeKid,
+		algorithm: algorithm,
+		keys:      map[string]*Key{},
+	}, nil
+}
+
+// Loads every key of the directory.
+//
+// If something fails the keys that were already loaded are kept, so a broken
+// file on disk never leaves the app without keys.
+func (k *KeyRing) Load() error {
+	files, err := ioutil.ReadDir(k.dir)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*Key{}
+	var active *Key
+	var activeModTime time.Time
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		kt, kid, privateFile, publicFile, ok := k.keyFiles(f.Name())
+		if !ok {
+			continue
+		}
+
+		if _, exists := keys[kid]; exists {
+			return fmt.Errorf("There are two keys with kid %s", kid)
+		}
+
+		// The private key is optional for the asymmetric keys
+		privateInfo, err := os.Stat(privateFile)
+		if err != nil {
+			privateFile = ""
+		}
+
+		signKey, verifyKey, err := loadCertificates(kt, privateFile, publicFile)
+		if err != nil {
+			return fmt.Errorf("Could not load key %s: %v", kid, err)
+		}
+
+		key := &Key{
+			Kid:        kid,
+			Algorithm:  kt.alg,
+			Method:     kt.method,
+			PrivateKey: signKey,
+			PublicKey:  verifyKey,
+		}
+		keys[kid] = key
+
+		// Pick the active key, the configured one or the newest one
+		if signKey == nil || (k.algorithm != "" && kt.alg != k.algorithm) {
+			continue
+		}
+		if k.activeKid != "" {
+			if kid == k.activeKid {
+				active = key
+			}
+		} else if active == nil || privateInfo.ModTime().After(activeModTime) {
+			active = key
+			activeModTime = privateInfo.ModTime()
+		}
+	}
+
+	if active == nil {
+		if k.activeKid != "" {
+			return fmt.Errorf("Could not find the private key of the active kid %s (Algorithm: %s)", k.activeKid, k.algorithm)
+		}
+		return fmt.Errorf("Could not find any private key on %s (Algorithm: %s)", k.dir, k.algorithm)
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.active = active
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Returns the kind of key, the kid and the paths of the files of the key if
+// the file is the one that identifies a key (The public one, or the secret).
+func (k *KeyRing) keyFiles(name string) (keyType, string, string, string, bool) {
+	for _, kt := range keyTypes {
+		if kt.publicExt == "" {
+			if strings.HasSuffix(name, kt.privateExt) {
+				kid := strings.TrimSuffix(name, kt.privateExt)
+				return kt, kid, filepath.Join(k.dir, name), "", true
+			}
+			continue
+		}
+
+		if strings.HasSuffix(name, kt.publicExt) {
+			kid := strings.TrimSuffix(name, kt.publicExt)
+			return kt, kid, filepath.Join(k.dir, kid+kt.privateExt), filepath.Join(k.dir, name), true
+		}
+	}
+
+	return keyType{}, "", "", "", false
+}
+
+// Returns the key that is used to sign the new tokens.
+func (k *KeyRing) SigningKey() (*Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.active == nil {
+		return nil, errors.New("There is no active signing key")
+	}
+
+	return k.active, nil
+}
+
+// Returns the key identified by the kid. If the kid is empty (Tokens signed
+// before the key ring existed) the active key is returned.
+func (k *KeyRing) VerificationKey(kid string) (*Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if kid == "" && k.active != nil {
+		return k.active, nil
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+// Returns every key of the ring.
+func (k *KeyRing) Keys() []*Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Reloads the keys when the process receives a SIGHUP and, if the interval
+// is greater than 0, every interval.
+func (k *KeyRing) Watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		tick = time.NewTicker(interval).C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-hup:
+			case <-tick:
+			}
+
+			if err := k.Load(); err != nil {
+				logger.Log().Errorf("Could not reload the keys. Reason: %v", err)
+				continue
+			}
+
+			active, _ := k.SigningKey()
+			logger.Log().Infof("Keys reloaded successfully, active kid: %s", active.Kid)
+		}
+	}()
+}
+
+var keys *KeyRing
+
+// Loads the keys of the directory (JWT) and uses them to sign and verify
+// the tokens. If activeKid is empty, the newest private key is used to sign.
+// If algorithm is not empty (RS256, ES256, EdDSA or HS256) only the keys of
+// that algorithm can be used to sign.
+func LoadKeys(dir, activeKid, algorithm string) error {
+	ring, err := NewKeyRing(dir, activeKid, algorithm)
+	if err != nil {
+		return err
+	}
+
+	if err := ring.Load(); err != nil {
+		return err
+	}
+
+	keys = ring
+	return nil
+}
+
+// Reloads the keys on SIGHUP and every interval (See KeyRing.Watch).
+func WatchKeys(interval time.Duration) {
+	keys.Watch(interval)
+}
+
+// Returns nil if the keys are loaded and there is one to sign the tokens.
+// It's used by the readiness check, the context is not needed.
+func CheckKeys(ctx context.Context) error {
+	if keys == nil {
+		return ErrKeysNotLoaded
+	}
+
+	_, err := keys.SigningKey()
+	return err
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Writes a new key of the algorithm with the kid as name on the directory
+func writeTestKey(t *testing.T, dir, kid, alg string) {
+	kt, _ := keyTypeByAlg(alg)
+
+	var private, public interface{}
+	switch alg {
+	case "RS256":
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		private, public = key, &key.PublicKey
+	case "ES256":
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		private, public = key, &key.PublicKey
+	case "EdDSA":
+		pub, key, _ := ed25519.GenerateKey(rand.Reader)
+		private, public = key, pub
+	case "HS256":
+		secret := []byte(kid + "-a-secret-that-is-long-enough-for-hs256")
+		if err := ioutil.WriteFile(filepath.Join(dir, kid+kt.privateExt), secret, 0600); err != nil {
+			t.Fatalf("❌ Could not write the secret: %v", err)
+		}
+		return
+	}
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("❌ Could not marshal the private key: %v", err)
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatalf("❌ Could not marshal the public key: %v", err)
+	}
+
+	privateBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer})
+	publicBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+kt.privateExt), privateBytes, 0600); err != nil {
+		t.Fatalf("❌ Could not write the private key: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+kt.publicExt), publicBytes, 0644); err != nil {
+		t.Fatalf("❌ Could not write the public key: %v", err)
+	}
+}
+
+// Verify that tokens signed with a key keep working after rotating it
+// and stop working once it's retired.
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "old", "RS256")
+
+	if err := LoadKeys(dir, "old", ""); err != nil {
+		t.Fatalf("❌ Could not load the keys: %v", err)
+	}
+
+	oldToken, err := GenerateToken(context.Background(), models.User{Id: 1, Username: "ramiro"})
+	if err != nil {
+		t.Fatalf("❌ Could not generate the token: %v", err)
+	}
+
+	// Rotate to a new key
+	writeTestKey(t, dir, "new", "RS256")
+	keys.activeKid = "new"
+	if err := keys.Load(); err != nil {
+		t.Fatalf("❌ Could not reload the keys: %v", err)
+	}
+
+	active, _ := keys.SigningKey()
+	if active.Kid != "new" {
+		t.Errorf("❌ Expected the active kid to be new, got %s", active.Kid)
+	}
+	if len(keys.JWKS().Keys) != 2 {
+		t.Errorf("❌ Expected both keys to be published")
+	}
+	if _, err := ValidateToken(context.Background(), oldToken); err != nil {
+		t.Errorf("❌ The token signed with the old key was rejected: %v", err)
+	}
+
+	// Retire the old key
+	os.Remove(filepath.Join(dir, "old.rsa"))
+	os.Remove(filepath.Join(dir, "old.rsa.pub"))
+	if err := keys.Load(); err != nil {
+		t.Fatalf("❌ Could not reload the keys: %v", err)
+	}
+
+	if _, err := ValidateToken(context.Background(), oldToken); err == nil {
+		t.Errorf("❌ The token signed with a retired key was accepted")
+	} else {
+		t.Log("✅ Keys rotated and retired successfully.")
+	}
+}
+
+// Verify that every supported algorithm can sign and verify tokens.
+func TestSigningAlgorithms(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA", "HS256"} {
+		dir := t.TempDir()
+		writeTestKey(t, dir, "key", alg)
+
+		if err := LoadKeys(dir, "", alg); err != nil {
+			t.Fatalf("❌ Could not load the %s key: %v", alg, err)
+		}
+
+		token, err := GenerateToken(context.Background(), models.User{Id: 1, Username: "ramiro"})
+		if err != nil {
+			t.Fatalf("❌ Could not generate the %s token: %v", alg, err)
+		}
+
+		if _, err := ValidateToken(context.Background(), token); err != nil {
+			t.Errorf("❌ The %s token was rejected: %v", alg, err)
+		} else {
+			t.Logf("✅ %s token signed and verified successfully.", alg)
+		}
+	}
+}
+
+// Verify that a token signed with HS256 using the RSA public key as secret
+// is rejected (Algorithm confusion).
+func TestAlgorithmConfusion(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "key", "RS256")
+
+	if err := LoadKeys(dir, "", ""); err != nil {
+		t.Fatalf("❌ Could not load the keys: %v", err)
+	}
+
+	publicBytes, _ := ioutil.ReadFile(filepath.Join(dir, "key.rsa.pub"))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, models.Claim{Username: "ramiro"})
+	token.Header["kid"] = "key"
+	forged, err := token.SignedString(publicBytes)
+	if err != nil {
+		t.Fatalf("❌ Could not forge the token: %v", err)
+	}
+
+	if _, err := ValidateToken(context.Background(), forged); err == nil {
+		t.Errorf("❌ The forged token was accepted")
+	} else {
+		t.Log("✅ The forged token was rejected.")
+	}
+}
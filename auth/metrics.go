@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Returns the result of the validation of a token used on the metrics: valid
+// or the reason why it was rejected.
+func validationResult(err error) string {
+	if err == nil {
+		return "valid"
+	}
+
+	switch {
+	case errors.Is(err, ErrTokenRevoked):
+		return "revoked"
+	case errors.Is(err, ErrRefreshTokenInvalid):
+		return "unknown"
+	case errors.Is(err, ErrRefreshTokenExpired):
+		return "expired"
+	case errors.Is(err, ErrRefreshTokenReused):
+		return "reused"
+	}
+
+	var vErr *jwt.ValidationError
+	if !errors.As(err, &vErr) {
+		return "error"
+	}
+
+	switch {
+	case vErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return "malformed"
+	// The signature does not match or the algorithm is not accepted
+	case vErr.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return "bad_signature"
+	// The kid is unknown or the algorithm is not the one of the key
+	case vErr.Errors&jwt.ValidationErrorUnverifiable != 0:
+		return "unverifiable"
+	case vErr.Errors&jwt.ValidationErrorExpired != 0:
+		return "expired"
+	case vErr.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+		return "not_valid_yet"
+	default:
+		return "invalid_claims"
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+// Options of the tokens issued and accepted by the app.
+type Options struct {
+	// Issuer (iss) of the tokens, only tokens with this issuer are accepted.
+	Issuer string
+	// Audience (aud) of the tokens, only tokens meant for this audience are accepted.
+	// Every service should use its own audience, so that the tokens minted for one
+	// of them can't be replayed at another.
+	Audience string
+	// Lifetime of the access tokens (JWT).
+	AccessTokenDuration time.Duration
+	// Lifetime of the refresh tokens.
+	RefreshTokenDuration time.Duration
+	// Lifetime of the password reset tokens.
+	ResetTokenDuration time.Duration
+	// Lifetime of the email verification tokens.
+	VerificationTokenDuration time.Duration
+	// Clock skew tolerated when checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
+// Returns the options used if Configure is never called.
+func DefaultOptions() Options {
+	return Options{
+		Issuer:                    "go-jwt-auth",
+		Audience:                  "go-jwt-auth",
+		AccessTokenDuration:       time.Hour * 2,       // 2hs to expire
+		RefreshTokenDuration:      time.Hour * 24 * 30, // 30 days to expire
+		ResetTokenDuration:        time.Hour,           // 1h to expire
+		VerificationTokenDuration: time.Hour * 24,      // 1 day to expire
+		Leeway:                    time.Second * 30,
+	}
+}
+
+var options = DefaultOptions()
+
+// Sets the options of the tokens.
+// It may be called from the main package at the start of the application.
+func Configure(o Options) error {
+	if o.Issuer == "" {
+		return errors.New("The issuer of the tokens can not be empty")
+	}
+	if o.Audience == "" {
+		return errors.New("The audience of the tokens can not be empty")
+	}
+	if o.AccessTokenDuration <= 0 || o.RefreshTokenDuration <= 0 || o.ResetTokenDuration <= 0 || o.VerificationTokenDuration <= 0 {
+		return errors.New("The lifetime of the tokens must be greater than 0")
+	}
+	if o.Leeway < 0 {
+		return errors.New("The leeway can not be negative")
+	}
+
+	options = o
+	return nil
+}
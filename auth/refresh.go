@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/metrics"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("Refresh token not found")
+	ErrRefreshTokenInvalid  = errors.New("Invalid refresh token")
+	ErrRefreshTokenExpired  = errors.New("Refresh token has expired")
+	ErrRefreshTokenReused   = errors.New("Refresh token was already used, the session has been revoked")
+)
+
+// RefreshToken is the server side record of an opaque refresh token.
+//
+// We never store the token itself, only a sha256 hash of it. Every token
+// belongs to a family (One family per login), when a token is rotated the
+// new one inherits the family so that we can revoke the whole chain at once.
+type RefreshToken struct {
+	Id        int64
+	UserId    int64
+	FamilyId  string
+	Hash      string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RotatedAt time.Time // Zero while the token has not been exchanged
+	RevokedAt time.Time // Zero while the token has not been revoked
+}
+
+// RefreshStore is where the refresh tokens are persisted.
+type RefreshStore interface {
+	// Create stores a new refresh token and assigns its id.
+	Create(t *RefreshToken) error
+	// GetByHash returns ErrRefreshTokenNotFound if there is no such token.
+	GetByHash(hash string) (RefreshToken, error)
+	// MarkRotated flags the token as used. It must be atomic and return
+	// false if the token was already rotated or revoked.
+	MarkRotated(id int64) (bool, error)
+	// RevokeFamily revokes every token that belongs to the family.
+	RevokeFamily(familyId string) error
+	// RevokeUser revokes every token that belongs to the user.
+	RevokeUser(userId int64) error
+}
+
+var refreshStore RefreshStore
+
+// Sets the store used to persist the refresh tokens.
+// It may be called from the main package at the start of the application.
+func SetRefreshStore(s RefreshStore) {
+	refreshStore = s
+}
+
+// Generates a new opaque refresh token for the user, it starts a new family.
+func IssueRefreshToken(userId int64) (string, error) {
+	familyId, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	return issueRefreshToken(userId, familyId)
+}
+
+// Exchanges a refresh token for a new one.
+//
+// Returns the id of the owner of the token so that a new access token can be
+// generated. If the received token was already rotated it means that someone
+// is replaying it, so the whole family is revoked.
+func RotateRefreshToken(token string) (int64, string, error) {
+	userId, newToken, err := rotateRefreshToken(token)
+	metrics.ObserveTokenValidated("refresh", validationResult(err))
+
+	return userId, newToken, err
+}
+
+func rotateRefreshToken(token string) (int64, string, error) {
+	if refreshStore == nil {
+		return 0, "", errors.New("Refresh token store is not configured")
+	}
+
+	stored, err := refreshStore.GetByHash(hashRefreshToken(token))
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	// A revoked token can not be used anymore
+	if !stored.RevokedAt.IsZero() {
+		return 0, "", ErrRefreshTokenInvalid
+	}
+
+	// The token was already exchanged, somebody is reusing it
+	if !stored.RotatedAt.IsZero() {
+		return 0, "", revokeReusedFamily(stored.FamilyId)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return 0, "", ErrRefreshTokenExpired
+	}
+
+	// If we could not mark it, another request rotated it in the meantime
+	ok, err := refreshStore.MarkRotated(stored.Id)
+	if err != nil {
+		return 0, "", err
+	}
+	if !ok {
+		return 0, "", revokeReusedFamily(stored.FamilyId)
+	}
+
+	newToken, err := issueRefreshToken(stored.UserId, stored.FamilyId)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return stored.UserId, newToken, nil
+}
+
+// Revokes the family of the refresh token of the user (Used on logout).
+//
+// Unknown tokens are ignored, there is nothing to revoke. The tokens of other
+// users are not revoked and ErrRefreshTokenInvalid is returned, so nobody can
+// close the session of someone else.
+func RevokeRefreshToken(token string, userId int64) error {
+	if refreshStore == nil {
+		return errors.New("Refresh token store is not configured")
+	}
+
+	stored, err := refreshStore.GetByHash(hashRefreshToken(token))
+	if errors.Is(err, ErrRefreshTokenNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if stored.UserId != userId {
+		return ErrRefreshTokenInvalid
+	}
+
+	return refreshStore.RevokeFamily(stored.FamilyId)
+}
+
+// Generates the token, stores its hash and returns the token itself.
+func issueRefreshToken(userId int64, familyId string) (string, error) {
+	if refreshStore == nil {
+		return "", errors.New("Refresh token store is not configured")
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	t := RefreshToken{
+		UserId:    userId,
+		FamilyId:  familyId,
+		Hash:      hashRefreshToken(token),
+		ExpiresAt: now.Add(options.RefreshTokenDuration),
+		CreatedAt: now,
+	}
+
+	if err := refreshStore.Create(&t); err != nil {
+		return "", err
+	}
+
+	metrics.ObserveTokenIssued("refresh")
+	return token, nil
+}
+
+// Revokes the family and returns the error that should be sent to the client.
+func revokeReusedFamily(familyId string) error {
+	if err := refreshStore.RevokeFamily(familyId); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// Hashes the refresh token, it's what we store on the database.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generates a random url safe string from n random bytes.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
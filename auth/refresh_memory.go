@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// In memory implementation of the RefreshStore, the tokens are lost when
+// the app stops. It's meant for tests and local development.
+type memoryRefreshStore struct {
+	mu     sync.Mutex
+	tokens map[int64]*RefreshToken
+	nextId int64
+}
+
+// Returns an empty in memory RefreshStore.
+func NewMemoryRefreshStore() RefreshStore {
+	return &memoryRefreshStore{tokens: map[int64]*RefreshToken{}}
+}
+
+func (s *memoryRefreshStore) Create(t *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextId++
+	t.Id = s.nextId
+	stored := *t
+	s.tokens[t.Id] = &stored
+	return nil
+}
+
+func (s *memoryRefreshStore) GetByHash(hash string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Hash == hash {
+			return *t, nil
+		}
+	}
+	return RefreshToken{}, ErrRefreshTokenNotFound
+}
+
+func (s *memoryRefreshStore) MarkRotated(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok || !t.RotatedAt.IsZero() || !t.RevokedAt.IsZero() {
+		return false, nil
+	}
+	t.RotatedAt = time.Now()
+	return true, nil
+}
+
+func (s *memoryRefreshStore) RevokeFamily(familyId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.FamilyId == familyId && t.RevokedAt.IsZero() {
+			t.RevokedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (s *memoryRefreshStore) RevokeUser(userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.UserId == userId && t.RevokedAt.IsZero() {
+			t.RevokedAt = time.Now()
+		}
+	}
+	return nil
+}
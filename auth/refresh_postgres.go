@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Postgres implementation of the RefreshStore
+type postgresRefreshStore struct {
+	db *sql.DB
+}
+
+// Returns a RefreshStore that persists the tokens on the refresh_tokens table.
+func NewPostgresRefreshStore(db *sql.DB) RefreshStore {
+	return &postgresRefreshStore{db}
+}
+
+func (s *postgresRefreshStore) Create(t *RefreshToken) error {
+	q := `
+	INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
+	`
+
+	return s.db.QueryRow(
+		q,
+		t.UserId,
+		t.FamilyId,
+		t.Hash,
+		t.ExpiresAt.UTC(), // The columns don't store the time zone
+		t.CreatedAt.UTC(),
+	).Scan(&t.Id)
+}
+
+func (s *postgresRefreshStore) GetByHash(hash string) (RefreshToken, error) {
+	q := `
+	SELECT id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at
+	FROM refresh_tokens WHERE token_hash = $1
+	`
+
+	t := RefreshToken{}
+
+	// Prepare null values
+	nullRotated := pq.NullTime{}
+	nullRevoked := pq.NullTime{}
+
+	err := s.db.QueryRow(q, hash).Scan(
+		&t.Id,
+		&t.UserId,
+		&t.FamilyId,
+		&t.Hash,
+		&t.ExpiresAt,
+		&t.CreatedAt,
+		&nullRotated,
+		&nullRevoked,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RefreshToken{}, ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	t.RotatedAt = nullRotated.Time
+	t.RevokedAt = nullRevoked.Time
+
+	return t, nil
+}
+
+func (s *postgresRefreshStore) MarkRotated(id int64) (bool, error) {
+	// The WHERE clause makes the update atomic, only one request can win
+	q := `
+	UPDATE refresh_tokens SET rotated_at = now()
+	WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`
+
+	res, err := s.db.Exec(q, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (s *postgresRefreshStore) RevokeFamily(familyId string) error {
+	q := `
+	UPDATE refresh_tokens SET revoked_at = now()
+	WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := s.db.Exec(q, familyId)
+	return err
+}
+
+func (s *postgresRefreshStore) RevokeUser(userId int64) error {
+	q := `
+	UPDATE refresh_tokens SET revoked_at = now()
+	WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	_, err := s.db.Exec(q, userId)
+	return err
+}
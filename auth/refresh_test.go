@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+// Verify that a refresh token can be exchanged for a new one.
+func TestRotateRefreshToken(t *testing.T) {
+	SetRefreshStore(NewMemoryRefreshStore())
+
+	token, err := IssueRefreshToken(7)
+	if err != nil {
+		t.Fatalf("❌ Could not issue the refresh token: %v", err)
+	}
+
+	userId, newToken, err := RotateRefreshToken(token)
+	if err != nil {
+		t.Fatalf("❌ Could not rotate the refresh token: %v", err)
+	}
+	if userId != 7 {
+		t.Errorf("❌ Expected user id 7, got %d", userId)
+	}
+	if newToken == "" || newToken == token {
+		t.Errorf("❌ The refresh token was not rotated")
+	} else {
+		t.Log("✅ Refresh token rotated successfully.")
+	}
+}
+
+// Verify that reusing an already rotated token revokes the whole family.
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	SetRefreshStore(NewMemoryRefreshStore())
+
+	token, err := IssueRefreshToken(7)
+	if err != nil {
+		t.Fatalf("❌ Could not issue the refresh token: %v", err)
+	}
+
+	_, newToken, err := RotateRefreshToken(token)
+	if err != nil {
+		t.Fatalf("❌ Could not rotate the refresh token: %v", err)
+	}
+
+	// Replay the first token
+	_, _, err = RotateRefreshToken(token)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("❌ Expected reuse to be detected, got: %v", err)
+	}
+
+	// The legitimate token must be revoked as well
+	_, _, err = RotateRefreshToken(newToken)
+	if !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("❌ Expected the family to be revoked, got: %v", err)
+	} else {
+		t.Log("✅ Refresh token family revoked after reuse.")
+	}
+}
+
+// Verify that an unknown token is rejected.
+func TestRotateUnknownRefreshToken(t *testing.T) {
+	SetRefreshStore(NewMemoryRefreshStore())
+
+	_, _, err := RotateRefreshToken("not-a-token")
+	if !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("❌ Expected an invalid token error, got: %v", err)
+	} else {
+		t.Log("✅ Unknown refresh token rejected.")
+	}
+}
+
+// Verify that the refresh token of another user is not revoked.
+func TestRevokeRefreshTokenOfAnotherUser(t *testing.T) {
+	SetRefreshStore(NewMemoryRefreshStore())
+
+	token, err := IssueRefreshToken(7)
+	if err != nil {
+		t.Fatalf("❌ Could not issue the refresh token: %v", err)
+	}
+
+	err = RevokeRefreshToken(token, 8)
+	if !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("❌ Expected the token of another user to be rejected, got: %v", err)
+	}
+
+	if _, _, err = RotateRefreshToken(token); err != nil {
+		t.Errorf("❌ The token of another user was revoked: %v", err)
+	}
+
+	if err = RevokeRefreshToken("not-a-token", 7); err != nil {
+		t.Errorf("❌ Expected an unknown token to be ignored, got: %v", err)
+	} else {
+		t.Log("✅ Only the refresh tokens of the user are revoked.")
+	}
+}
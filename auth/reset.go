@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrResetTokenNotFound = errors.New("Reset token not found")
+	ErrResetTokenInvalid  = errors.New("Invalid or already used reset token")
+	ErrResetTokenExpired  = errors.New("Reset token has expired, ask for a new one")
+)
+
+// ResetToken is the server side record of a password reset token.
+//
+// As with the refresh tokens, only a sha256 hash of the token is stored. A
+// token can only be used once, and issuing a new one invalidates the previous
+// ones of the user.
+type ResetToken struct {
+	Id        int64
+	UserId    int64
+	Hash      string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    time.Time // Zero while the token has not been used or invalidated
+}
+
+// ResetStore is where the reset tokens are persisted.
+type ResetStore interface {
+	// Create stores a new reset token and assigns its id.
+	Create(t *ResetToken) error
+	// GetByHash returns ErrResetTokenNotFound if there is no such token.
+	GetByHash(hash string) (ResetToken, error)
+	// MarkUsed flags the token as used. It must be atomic and return false
+	// if the token was already used.
+	MarkUsed(id int64) (bool, error)
+	// InvalidateUser flags every unused token of the user as used.
+	InvalidateUser(userId int64) error
+}
+
+var resetStore ResetStore
+
+// Sets the store used to persist the reset tokens.
+// It may be called from the main package at the start of the application.
+func SetResetStore(s ResetStore) {
+	resetStore = s
+}
+
+// Generates a new reset token for the user and returns it with its expiration
+// date, the previous tokens of the user can not be used anymore.
+func IssueResetToken(userId int64) (string, time.Time, error) {
+	if resetStore == nil {
+		return "", time.Time{}, errors.New("Reset token store is not configured")
+	}
+
+	if err := resetStore.InvalidateUser(userId); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	t := ResetToken{
+		UserId:    userId,
+		Hash:      hashRefreshToken(token),
+		ExpiresAt: now.Add(options.ResetTokenDuration),
+		CreatedAt: now,
+	}
+
+	if err := resetStore.Create(&t); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, t.ExpiresAt, nil
+}
+
+// Returns the owner of the reset token without using it, so that the new
+// password can be checked before the token is spent (See ConsumeResetToken).
+func CheckResetToken(token string) (int64, error) {
+	stored, err := getResetToken(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return stored.UserId, nil
+}
+
+// Uses the reset token and returns its owner. Only one request can use a
+// token, the others get ErrResetTokenInvalid.
+func ConsumeResetToken(token string) (int64, error) {
+	stored, err := getResetToken(token)
+	if err != nil {
+		return 0, err
+	}
+
+	ok, err := resetStore.MarkUsed(stored.Id)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrResetTokenInvalid
+	}
+
+	// Any other token issued to the user is not needed anymore
+	if err := resetStore.InvalidateUser(stored.UserId); err != nil {
+		return 0, err
+	}
+
+	return stored.UserId, nil
+}
+
+// Returns the stored token if it can still be used.
+func getResetToken(token string) (ResetToken, error) {
+	if resetStore == nil {
+		return ResetToken{}, errors.New("Reset token store is not configured")
+	}
+
+	stored, err := resetStore.GetByHash(hashRefreshToken(token))
+	if errors.Is(err, ErrResetTokenNotFound) {
+		return ResetToken{}, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return ResetToken{}, err
+	}
+
+	if !stored.UsedAt.IsZero() {
+		return ResetToken{}, ErrResetTokenInvalid
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return ResetToken{}, ErrResetTokenExpired
+	}
+
+	return stored, nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// In memory implementation of the ResetStore, the tokens are lost when the
+// app stops. It's meant for tests and local development.
+type memoryResetStore struct {
+	mu     sync.Mutex
+	tokens map[int64]*ResetToken
+	nextId int64
+}
+
+// Returns an empty in memory ResetStore.
+func NewMemoryResetStore() ResetStore {
+	return &memoryResetStore{tokens: map[int64]*ResetToken{}}
+}
+
+func (s *memoryResetStore) Create(t *ResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextId++
+	t.Id = s.nextId
+	stored := *t
+	s.tokens[t.Id] = &stored
+	return nil
+}
+
+func (s *memoryResetStore) GetByHash(hash string) (ResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.Hash == hash {
+			return *t, nil
+		}
+	}
+	return ResetToken{}, ErrResetTokenNotFound
+}
+
+func (s *memoryResetStore) MarkUsed(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok || !t.UsedAt.IsZero() {
+		return false, nil
+	}
+	t.UsedAt = time.Now()
+	return true, nil
+}
+
+func (s *memoryResetStore) InvalidateUser(userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The expired tokens are useless, they are removed
+	for id, t := range s.tokens {
+		if time.Now().After(t.ExpiresAt) {
+			delete(s.tokens, id)
+			continue
+		}
+		if t.UserId == userId && t.UsedAt.IsZero() {
+			t.UsedAt = time.Now()
+		}
+	}
+	return nil
+}
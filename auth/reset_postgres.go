@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Postgres implementation of the ResetStore
+type postgresResetStore struct {
+	db *sql.DB
+}
+
+// Returns a ResetStore that persists the tokens on the password_reset_tokens table.
+func NewPostgresResetStore(db *sql.DB) ResetStore {
+	return &postgresResetStore{db}
+}
+
+func (s *postgresResetStore) Create(t *ResetToken) error {
+	q := `
+	INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id
+	`
+
+	return s.db.QueryRow(
+		q,
+		t.UserId,
+		t.Hash,
+		t.ExpiresAt.UTC(), // The columns don't store the time zone
+		t.CreatedAt.UTC(),
+	).Scan(&t.Id)
+}
+
+func (s *postgresResetStore) GetByHash(hash string) (ResetToken, error) {
+	q := `
+	SELECT id, user_id, token_hash, expires_at, created_at, used_at
+	FROM password_reset_tokens WHERE token_hash = $1
+	`
+
+	t := ResetToken{}
+
+	// Prepare null values
+	nullUsed := pq.NullTime{}
+
+	err := s.db.QueryRow(q, hash).Scan(
+		&t.Id,
+		&t.UserId,
+		&t.Hash,
+		&t.ExpiresAt,
+		&t.CreatedAt,
+		&nullUsed,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ResetToken{}, ErrResetTokenNotFound
+	}
+	if err != nil {
+		return ResetToken{}, err
+	}
+
+	t.UsedAt = nullUsed.Time
+
+	return t, nil
+}
+
+func (s *postgresResetStore) MarkUsed(id int64) (bool, error) {
+	// The WHERE clause makes the update atomic, only one request can win
+	q := `
+	UPDATE password_reset_tokens SET used_at = (now() AT TIME ZONE 'UTC')
+	WHERE id = $1 AND used_at IS NULL
+	`
+
+	res, err := s.db.Exec(q, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (s *postgresResetStore) InvalidateUser(userId int64) error {
+	// The expired tokens are useless, they are removed
+	q := `DELETE FROM password_reset_tokens WHERE expires_at < (now() AT TIME ZONE 'UTC')`
+	if _, err := s.db.Exec(q); err != nil {
+		return err
+	}
+
+	q = `
+	UPDATE password_reset_tokens SET used_at = (now() AT TIME ZONE 'UTC')
+	WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	_, err := s.db.Exec(q, userId)
+	return err
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Verify that a reset token can be used only once.
+func TestConsumeResetToken(t *testing.T) {
+	SetResetStore(NewMemoryResetStore())
+
+	token, expiresAt, err := IssueResetToken(7)
+	if err != nil {
+		t.Fatalf("❌ Could not issue the reset token: %v", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		t.Errorf("❌ The token is already expired: %v", expiresAt)
+	}
+
+	if userId, err := CheckResetToken(token); err != nil || userId != 7 {
+		t.Fatalf("❌ Expected the token of user 7, got %d %v", userId, err)
+	}
+
+	userId, err := ConsumeResetToken(token)
+	if err != nil || userId != 7 {
+		t.Fatalf("❌ Could not use the reset token: %d %v", userId, err)
+	}
+
+	_, err = ConsumeResetToken(token)
+	if !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("❌ Expected the used token to be rejected, got: %v", err)
+	} else {
+		t.Log("✅ Reset token used only once.")
+	}
+}
+
+// Verify that issuing a new token invalidates the previous ones of the user.
+func TestIssueResetTokenInvalidatesPrevious(t *testing.T) {
+	SetResetStore(NewMemoryResetStore())
+
+	first, _, _ := IssueResetToken(7)
+	other, _, _ := IssueResetToken(8)
+	second, _, _ := IssueResetToken(7)
+
+	if _, err := CheckResetToken(first); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("❌ Expected the previous token to be invalid, got: %v", err)
+	}
+	if _, err := CheckResetToken(second); err != nil {
+		t.Errorf("❌ The new token should be valid: %v", err)
+	}
+	if _, err := CheckResetToken(other); err != nil {
+		t.Errorf("❌ The tokens of other users should be valid: %v", err)
+	} else {
+		t.Log("✅ Previous reset tokens invalidated.")
+	}
+}
+
+// Verify that unknown and expired tokens are rejected.
+func TestCheckResetTokenErrors(t *testing.T) {
+	store := NewMemoryResetStore()
+	SetResetStore(store)
+
+	if _, err := CheckResetToken("unknown"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("❌ Expected an unknown token to be invalid, got: %v", err)
+	}
+
+	store.Create(&ResetToken{UserId: 7, Hash: hashRefreshToken("expired"), ExpiresAt: time.Now().Add(-time.Minute)})
+
+	if _, err := ConsumeResetToken("expired"); !errors.Is(err, ErrResetTokenExpired) {
+		t.Errorf("❌ Expected an expired token to be rejected, got: %v", err)
+	} else {
+		t.Log("✅ Unknown and expired reset tokens rejected.")
+	}
+}
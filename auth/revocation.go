@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+var ErrTokenRevoked = errors.New("Token has been revoked")
+
+// UserRevocation invalidates the tokens of a user issued until Before, except
+// the token with the jti Keep (e.g. the new session of a password change).
+type UserRevocation struct {
+	Before time.Time
+	Keep   string
+}
+
+// RevocationBackend is where the revoked tokens are persisted.
+type RevocationBackend interface {
+	// RevokeToken stores the jti of a token, it only needs to be kept
+	// until the token expires.
+	RevokeToken(jti string, expiresAt time.Time) error
+	// RevokeUser invalidates every token of the user issued before the date,
+	// except the one that is kept.
+	RevokeUser(userId int64, revocation UserRevocation) error
+	// Load returns the tokens that are still revoked (Not expired yet) and
+	// the revocation of every user.
+	Load() (map[string]time.Time, map[int64]UserRevocation, error)
+}
+
+// RevocationList keeps in memory a copy of the revoked tokens so that we
+// don't have to hit the database on every request.
+//
+// Writes go to the backend and to the cache, the cache is also reloaded
+// periodically so that revocations made by other replicas are picked up.
+type RevocationList struct {
+	backend RevocationBackend
+	mu      sync.RWMutex
+	tokens  map[string]time.Time     // jti -> expiration of the token
+	users   map[int64]UserRevocation // user id -> tokens issued before are revoked
+}
+
+// Returns a new RevocationList, it does not load the backend (See Sync).
+func NewRevocationList(backend RevocationBackend) *RevocationList {
+	return &RevocationList{
+		backend: backend,
+		tokens:  map[string]time.Time{},
+		users:   map[int64]UserRevocation{},
+	}
+}
+
+// Reloads the cache from the backend.
+func (l *RevocationList) Sync() error {
+	tokens, users, err := l.backend.Load()
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.tokens = tokens
+	l.users = users
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Reloads the cache every interval on a separate goroutine, until the
+// returned function is called.
+func (l *RevocationList) StartSync(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.Sync(); err != nil {
+					logger.Log().Errorf("Could not sync the revoked tokens. Reason: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// Revokes a single token until it expires.
+func (l *RevocationList) RevokeToken(jti string, expiresAt time.Time) error {
+	if err := l.backend.RevokeToken(jti, expiresAt); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.tokens[jti] = expiresAt
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Revokes every token issued to the user until now, except the token with the
+// jti keep (Empty to revoke them all).
+func (l *RevocationList) RevokeUser(userId int64, keep string) error {
+	revocation := UserRevocation{Before: time.Now(), Keep: keep}
+
+	if err := l.backend.RevokeUser(userId, revocation); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.users[userId] = revocation
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Checks if the token described by the claim has been revoked.
+func (l *RevocationList) IsRevoked(claim models.Claim) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.tokens[claim.ID]; ok {
+		return true
+	}
+
+	// The iat claim has a precision of seconds, so a token issued on the same
+	// second of the revocation is also considered revoked, unless it's the
+	// token that was kept
+	revocation, ok := l.users[claim.UserId()]
+	if !ok || (revocation.Keep != "" && claim.ID == revocation.Keep) {
+		return false
+	}
+
+	if claim.IssuedAt == nil || claim.IssuedAt.Unix() <= revocation.Before.Unix() {
+		return true
+	}
+
+	return false
+}
+
+var revocations *RevocationList
+
+// Sets the list that ValidateToken consults to reject revoked tokens.
+// It may be called from the main package at the start of the application.
+func SetRevocationList(l *RevocationList) {
+	revocations = l
+}
+
+// Revokes the access token described by the claim (Used on logout).
+func RevokeToken(claim models.Claim) error {
+	if revocations == nil {
+		return errors.New("Revocation list is not configured")
+	}
+
+	if claim.ExpiresAt == nil {
+		return errors.New("The token does not expire")
+	}
+
+	return revocations.RevokeToken(claim.ID, claim.ExpiresAt.Time)
+}
+
+// Revokes every access and refresh token issued to the user.
+func RevokeAllForUser(userId int64) error {
+	return revokeAllForUser(userId, "")
+}
+
+// Same as RevokeAllForUser, but the access token with the jti keep is still
+// valid. It's the new session of the user, issued before the revocation so
+// that its jti is known, the refresh token must be issued after.
+func RevokeAllForUserExcept(userId int64, keep string) error {
+	if keep == "" {
+		return errors.New("The jti of the kept token is empty")
+	}
+
+	return revokeAllForUser(userId, keep)
+}
+
+func revokeAllForUser(userId int64, keep string) error {
+	if revocations == nil {
+		return errors.New("Revocation list is not configured")
+	}
+
+	if err := revocations.RevokeUser(userId, keep); err != nil {
+		return err
+	}
+
+	if refreshStore != nil {
+		return refreshStore.RevokeUser(userId)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// In memory implementation of the RevocationBackend, the revocations are lost
+// when the app stops. It's meant for tests and local development.
+type memoryRevocationBackend struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	users  map[int64]UserRevocation
+}
+
+// Returns an empty in memory RevocationBackend.
+func NewMemoryRevocationBackend() RevocationBackend {
+	return &memoryRevocationBackend{
+		tokens: map[string]time.Time{},
+		users:  map[int64]UserRevocation{},
+	}
+}
+
+func (b *memoryRevocationBackend) RevokeToken(jti string, expiresAt time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens[jti] = expiresAt
+	return nil
+}
+
+func (b *memoryRevocationBackend) RevokeUser(userId int64, revocation UserRevocation) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.users[userId] = revocation
+	return nil
+}
+
+func (b *memoryRevocationBackend) Load() (map[string]time.Time, map[int64]UserRevocation, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Return copies, the list keeps its own maps
+	tokens := map[string]time.Time{}
+	for jti, expiresAt := range b.tokens {
+		if time.Now().Before(expiresAt) {
+			tokens[jti] = expiresAt
+		}
+	}
+
+	users := map[int64]UserRevocation{}
+	for userId, revocation := range b.users {
+		users[userId] = revocation
+	}
+
+	return tokens, users, nil
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Postgres implementation of the RevocationBackend
+type postgresRevocationBackend struct {
+	db *sql.DB
+}
+
+// Returns a RevocationBackend that persists the revocations on the
+// revoked_tokens and user_token_revocations tables.
+func NewPostgresRevocationBackend(db *sql.DB) RevocationBackend {
+	return &postgresRevocationBackend{db}
+}
+
+func (b *postgresRevocationBackend) RevokeToken(jti string, expiresAt time.Time) error {
+	q := `
+	INSERT INTO revoked_tokens (jti, expires_at, revoked_at)
+	VALUES ($1, $2, now())
+	ON CONFLICT (jti) DO NOTHING
+	`
+
+	_, err := b.db.Exec(q, jti, expiresAt.UTC())
+	return err
+}
+
+func (b *postgresRevocationBackend) RevokeUser(userId int64, revocation UserRevocation) error {
+	q := `
+	INSERT INTO user_token_revocations (user_id, revoked_before, kept_jti)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE
+	SET revoked_before = EXCLUDED.revoked_before, kept_jti = EXCLUDED.kept_jti
+	`
+
+	_, err := b.db.Exec(q, userId, revocation.Before.UTC(), revocation.Keep)
+	return err
+}
+
+func (b *postgresRevocationBackend) Load() (map[string]time.Time, map[int64]UserRevocation, error) {
+	// 1° Expired tokens are rejected anyway, so we can forget about them
+	_, err := b.db.Exec(`DELETE FROM revoked_tokens WHERE expires_at <= (now() AT TIME ZONE 'UTC')`)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 2° Fetch the revoked tokens
+	tokens := map[string]time.Time{}
+
+	rows, err := b.db.Query(`SELECT jti, expires_at FROM revoked_tokens`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jti string
+		var expiresAt time.Time
+
+		if err := rows.Scan(&jti, &expiresAt); err != nil {
+			return nil, nil, err
+		}
+
+		tokens[jti] = expiresAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// 3° Fetch the revoked users
+	users := map[int64]UserRevocation{}
+
+	userRows, err := b.db.Query(`SELECT user_id, revoked_before, kept_jti FROM user_token_revocations`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer userRows.Close()
+
+	for userRows.Next() {
+		var userId int64
+		var revocation UserRevocation
+
+		if err := userRows.Scan(&userId, &revocation.Before, &revocation.Keep); err != nil {
+			return nil, nil, err
+		}
+
+		users[userId] = revocation
+	}
+	if err := userRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, users, nil
+}
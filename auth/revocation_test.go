@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func newTestRevocationList() *RevocationList {
+	return NewRevocationList(NewMemoryRevocationBackend())
+}
+
+// Verify that a revoked jti is rejected and the others are not.
+func TestRevokeToken(t *testing.T) {
+	l := newTestRevocationList()
+
+	revoked := models.Claim{RegisteredClaims: jwt.RegisteredClaims{ID: "revoked"}}
+	active := models.Claim{RegisteredClaims: jwt.RegisteredClaims{ID: "active"}}
+
+	if err := l.RevokeToken(revoked.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("❌ Could not revoke the token: %v", err)
+	}
+
+	if !l.IsRevoked(revoked) {
+		t.Errorf("❌ The revoked token was accepted")
+	}
+	if l.IsRevoked(active) {
+		t.Errorf("❌ The active token was rejected")
+	} else {
+		t.Log("✅ Only the revoked token was rejected.")
+	}
+}
+
+// Verify that revoking a user only rejects the tokens issued before.
+func TestRevokeUser(t *testing.T) {
+	l := newTestRevocationList()
+
+	old := models.Claim{RegisteredClaims: jwt.RegisteredClaims{Subject: "3", IssuedAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}}
+	other := models.Claim{RegisteredClaims: jwt.RegisteredClaims{Subject: "4", IssuedAt: old.IssuedAt}}
+
+	if err := l.RevokeUser(3, ""); err != nil {
+		t.Fatalf("❌ Could not revoke the user: %v", err)
+	}
+
+	fresh := models.Claim{RegisteredClaims: jwt.RegisteredClaims{Subject: "3", IssuedAt: jwt.NewNumericDate(time.Now().Add(time.Second))}}
+
+	if !l.IsRevoked(old) {
+		t.Errorf("❌ The token issued before the revocation was accepted")
+	}
+	if l.IsRevoked(other) {
+		t.Errorf("❌ The token of another user was rejected")
+	}
+	if l.IsRevoked(fresh) {
+		t.Errorf("❌ The token issued after the revocation was rejected")
+	} else {
+		t.Log("✅ Only the tokens issued before the revocation were rejected.")
+	}
+}
+
+// Verify that the kept token is not revoked with the others of the same
+// second, on this replica and on the ones that load the backend.
+func TestRevokeUserKeep(t *testing.T) {
+	backend := NewMemoryRevocationBackend()
+	l := NewRevocationList(backend)
+
+	now := jwt.NewNumericDate(time.Now())
+	kept := models.Claim{RegisteredClaims: jwt.RegisteredClaims{ID: "kept", Subject: "3", IssuedAt: now}}
+	other := models.Claim{RegisteredClaims: jwt.RegisteredClaims{ID: "other", Subject: "3", IssuedAt: now}}
+	noID := models.Claim{RegisteredClaims: jwt.RegisteredClaims{Subject: "3", IssuedAt: now}}
+
+	if err := l.RevokeUser(3, kept.ID); err != nil {
+		t.Fatalf("❌ Could not revoke the user: %v", err)
+	}
+
+	replica := NewRevocationList(backend)
+	if err := replica.Sync(); err != nil {
+		t.Fatalf("❌ Could not sync the replica: %v", err)
+	}
+
+	for _, list := range []*RevocationList{l, replica} {
+		if list.IsRevoked(kept) {
+			t.Errorf("❌ The kept token was rejected")
+		}
+		if !list.IsRevoked(other) || !list.IsRevoked(noID) {
+			t.Errorf("❌ A token of the same second was accepted")
+		}
+	}
+
+	if !t.Failed() {
+		t.Log("✅ Only the kept token was accepted.")
+	}
+}
+
+// Backend that counts how many times it's loaded
+type countingBackend struct {
+	RevocationBackend
+	loads int32
+}
+
+func (b *countingBackend) Load() (map[string]time.Time, map[int64]UserRevocation, error) {
+	atomic.AddInt32(&b.loads, 1)
+	return b.RevocationBackend.Load()
+}
+
+// Verify that the periodic sync stops when its stop function is called.
+func TestStopSync(t *testing.T) {
+	backend := &countingBackend{RevocationBackend: NewMemoryRevocationBackend()}
+	l := NewRevocationList(backend)
+
+	stop := l.StartSync(time.Millisecond)
+	time.Sleep(time.Millisecond * 20)
+	stop()
+	stop() // It can be called twice
+
+	loads := atomic.LoadInt32(&backend.loads)
+	if loads == 0 {
+		t.Fatalf("❌ The cache was never synced")
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	if after := atomic.LoadInt32(&backend.loads); after > loads+1 {
+		t.Errorf("❌ The cache kept syncing after the stop: %d loads, then %d", loads, after)
+	} else {
+		t.Log("✅ Sync stopped.")
+	}
+}
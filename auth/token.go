@@ -1,45 +1,103 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/RamiroCuenca/go-jwt-auth/common/metrics"
+	"github.com/RamiroCuenca/go-jwt-auth/common/tracing"
 	"github.com/RamiroCuenca/go-jwt-auth/users/models"
 	"github.com/golang-jwt/jwt/v4"
 )
 
 // Generates a JWT. It receives the data from the user who has logged in!
 // The JWT is a string
-func GenerateToken(user models.User) (string, error) {
+func GenerateToken(ctx context.Context, user models.User) (string, error) {
+	_, span := tracing.Start(ctx, "GenerateToken")
+	defer span.End()
+
+	token, err := generateToken(user)
+	tracing.RecordError(span, err)
+
+	return token, err
+}
+
+func generateToken(user models.User) (string, error) {
+	// Every token has a unique id (jti) so that it can be revoked
+	jti, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	emailVerified := user.EmailVerified
 	claim := models.Claim{
-		Username: user.Username,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 2).Unix(), // 2hs ot expire
-			Issuer:    "Ramiro Cuenca Salinas",
+		Username:      user.Username,
+		Role:          user.Role,
+		EmailVerified: &emailVerified,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatInt(user.Id, 10),
+			Issuer:    options.Issuer,
+			Audience:  jwt.ClaimStrings{options.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(options.AccessTokenDuration)),
 		},
 	}
 
-	// Prepared the token to be signed with RS256 method and including the claim
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claim)
-
-	// Sign the token with our private key
-	signedToken, err := token.SignedString(signKey)
+	signedToken, err := signToken(claim)
 	if err != nil {
 		return "", err
 	}
 
+	metrics.ObserveTokenIssued("access")
+
 	// Return the generated token
 	return signedToken, nil
 }
 
+// Signs the claims with the active key of the key ring
+func signToken(claims jwt.Claims) (string, error) {
+	if keys == nil {
+		return "", ErrKeysNotLoaded
+	}
+	key, err := keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	// Prepared the token to be signed with the method of the key and including
+	// the claim. The kid header tells which key has to be used to verify it.
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Kid
+
+	// Sign the token with our private key
+	return token.SignedString(key.PrivateKey)
+}
+
 // Validate the JWT.
 // Returns the claim so that we can access the information of the user.
 //
-// Before, we need to create a function wich return as the information of
-// our public key (Already parsed, it is declared as "verifyKey")
-func ValidateToken(t string) (models.Claim, error) {
+// Before, we need to create a function wich return as the public key
+// that signed the token (Looked up on the key ring by its kid)
+func ValidateToken(ctx context.Context, t string) (models.Claim, error) {
+	_, span := tracing.Start(ctx, "ValidateToken")
+	defer span.End()
+
+	claim, err := validateToken(t)
+	metrics.ObserveTokenValidated("access", validationResult(err))
+	tracing.RecordError(span, err)
+
+	return claim, err
+}
+
+func validateToken(t string) (models.Claim, error) {
 
-	token, err := jwt.ParseWithClaims(t, &models.Claim{}, verifyFunction)
+	token, err := parser.ParseWithClaims(t, &models.Claim{}, verifyFunction)
 	if err != nil {
 		return models.Claim{}, err
 	}
@@ -55,10 +113,80 @@ func ValidateToken(t string) (models.Claim, error) {
 		return models.Claim{}, errors.New("Couldn't fetch the claims")
 	}
 
+	// Check the registered claims (exp, nbf, iat, iss and aud)
+	err = validateClaims(*claim, time.Now())
+	if err != nil {
+		return models.Claim{}, err
+	}
+
+	// Check if the token was revoked (Logout or revoke all)
+	if revocations != nil && revocations.IsRevoked(*claim) {
+		return models.Claim{}, ErrTokenRevoked
+	}
+
 	return *claim, nil
 }
 
-// Returns the verifyKey wich is out public key already parsed
+// Only the supported algorithms are accepted ("none" is never accepted).
+//
+// The library does not tolerate clock skew, so we validate the claims
+// ourselves (See validateClaims).
+var parser = &jwt.Parser{
+	ValidMethods:         []string{"RS256", "ES256", "EdDSA", "HS256"},
+	SkipClaimsValidation: true,
+}
+
+// Checks the registered claims of the token, allowing options.Leeway of
+// clock skew between the services.
+func validateClaims(c models.Claim, now time.Time) error {
+	leeway := options.Leeway
+
+	if c.ExpiresAt == nil || now.After(c.ExpiresAt.Add(leeway)) {
+		return jwt.NewValidationError("Token is expired", jwt.ValidationErrorExpired)
+	}
+
+	if c.NotBefore != nil && now.Add(leeway).Before(c.NotBefore.Time) {
+		return jwt.NewValidationError("Token is not valid yet", jwt.ValidationErrorNotValidYet)
+	}
+
+	if c.IssuedAt != nil && now.Add(leeway).Before(c.IssuedAt.Time) {
+		return jwt.NewValidationError("Token used before issued", jwt.ValidationErrorIssuedAt)
+	}
+
+	if c.Issuer != options.Issuer {
+		return jwt.NewValidationError("Token has an invalid issuer", jwt.ValidationErrorIssuer)
+	}
+
+	if !c.VerifyAudience(options.Audience, true) {
+		return jwt.NewValidationError("Token has an invalid audience", jwt.ValidationErrorAudience)
+	}
+
+	if c.Subject == "" {
+		return jwt.NewValidationError("Token has no subject", jwt.ValidationErrorClaimsInvalid)
+	}
+
+	return nil
+}
+
+// Returns the public key (Already parsed) identified by the kid header
+//
+// The algorithm of the token must be the one of the key, otherwise a public
+// key could be used as an HMAC secret (Algorithm confusion).
 func verifyFunction(t *jwt.Token) (interface{}, error) {
-	return verifyKey, nil
+	if keys == nil {
+		return nil, ErrKeysNotLoaded
+	}
+
+	kid, _ := t.Header["kid"].(string)
+
+	key, err := keys.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("Unexpected signing method %s for key %s", t.Method.Alg(), key.Kid)
+	}
+
+	return key.PublicKey, nil
 }
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// Loads a new RS256 key and the default options
+func setupTestToken(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKey(t, dir, "key", "RS256")
+
+	if err := LoadKeys(dir, "", ""); err != nil {
+		t.Fatalf("❌ Could not load the keys: %v", err)
+	}
+
+	options = DefaultOptions()
+}
+
+// Verify that the token carries the standard claims.
+func TestGenerateTokenClaims(t *testing.T) {
+	setupTestToken(t)
+
+	token, err := GenerateToken(context.Background(), models.User{Id: 42, Username: "ramiro"})
+	if err != nil {
+		t.Fatalf("❌ Could not generate the token: %v", err)
+	}
+
+	claim, err := ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("❌ The token was rejected: %v", err)
+	}
+
+	if claim.UserId() != 42 || claim.Subject != "42" {
+		t.Errorf("❌ Expected the subject to be 42, got %s", claim.Subject)
+	}
+	if claim.ID == "" || claim.IssuedAt == nil || claim.NotBefore == nil || claim.ExpiresAt == nil {
+		t.Errorf("❌ The token is missing jti, iat, nbf or exp")
+	}
+	if claim.Issuer != options.Issuer || !claim.VerifyAudience(options.Audience, true) {
+		t.Errorf("❌ The token has a wrong issuer or audience")
+	}
+
+	// Other verifiers expect the dates to be whole seconds
+	payload, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	for _, date := range []string{"iat", "nbf", "exp"} {
+		if !regexp.MustCompile(`"` + date + `":\d+[,}]`).Match(payload) {
+			t.Errorf("❌ The %s is not a whole number of seconds: %s", date, payload)
+		}
+	}
+
+	if !t.Failed() {
+		t.Log("✅ Token generated with the standard claims.")
+	}
+}
+
+// Verify that a token minted for another audience or issuer is rejected.
+func TestValidateTokenAudienceAndIssuer(t *testing.T) {
+	setupTestToken(t)
+
+	options.Audience = "another-service"
+	token, err := GenerateToken(context.Background(), models.User{Id: 42, Username: "ramiro"})
+	if err != nil {
+		t.Fatalf("❌ Could not generate the token: %v", err)
+	}
+
+	options.Audience = DefaultOptions().Audience
+	if _, err := ValidateToken(context.Background(), token); err == nil {
+		t.Errorf("❌ The token of another audience was accepted")
+	}
+
+	options.Issuer = "another-issuer"
+	token, _ = GenerateToken(context.Background(), models.User{Id: 42, Username: "ramiro"})
+
+	options.Issuer = DefaultOptions().Issuer
+	if _, err := ValidateToken(context.Background(), token); err == nil {
+		t.Errorf("❌ The token of another issuer was accepted")
+	} else {
+		t.Log("✅ Tokens of other audiences and issuers were rejected.")
+	}
+}
+
+// Verify that the leeway is applied to the expiration.
+func TestValidateTokenLeeway(t *testing.T) {
+	setupTestToken(t)
+
+	// The token expired 10 seconds ago
+	options.AccessTokenDuration = -time.Second * 10
+	token, err := GenerateToken(context.Background(), models.User{Id: 42, Username: "ramiro"})
+	if err != nil {
+		t.Fatalf("❌ Could not generate the token: %v", err)
+	}
+
+	options.Leeway = time.Second * 30
+	if _, err := ValidateToken(context.Background(), token); err != nil {
+		t.Errorf("❌ The token was rejected in spite of the leeway: %v", err)
+	}
+
+	options.Leeway = 0
+	if _, err := ValidateToken(context.Background(), token); err == nil {
+		t.Errorf("❌ The expired token was accepted without leeway")
+	} else {
+		t.Log("✅ The leeway was applied to the expiration.")
+	}
+}
+
+// Verify that the rejected tokens are counted with the reason.
+func TestValidationResult(t *testing.T) {
+	setupTestToken(t)
+
+	token, err := GenerateToken(context.Background(), models.User{Id: 42, Username: "ramiro"})
+	if err != nil {
+		t.Fatalf("❌ Could not generate the token: %v", err)
+	}
+
+	tests := map[string]string{
+		token:                         "valid",
+		"not-a-token":                 "malformed",
+		token[:len(token)-4] + "AAAA": "bad_signature",
+	}
+
+	for tokenString, expected := range tests {
+		_, err := ValidateToken(context.Background(), tokenString)
+		if got := validationResult(err); got != expected {
+			t.Errorf("❌ Expected %s, got %s (%v)", expected, got, err)
+		}
+	}
+
+	options.AccessTokenDuration = -time.Hour
+	expired, _ := GenerateToken(context.Background(), models.User{Id: 42, Username: "ramiro"})
+	options = DefaultOptions()
+
+	_, err = ValidateToken(context.Background(), expired)
+	if got := validationResult(err); got != "expired" {
+		t.Errorf("❌ Expected expired, got %s", got)
+	} else {
+		t.Log("✅ Rejections classified by reason.")
+	}
+}
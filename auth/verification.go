@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrVerificationTokenInvalid = errors.New("Invalid verification token")
+	ErrVerificationTokenExpired = errors.New("Verification token has expired, ask for a new one")
+)
+
+// Claim of the tokens that verify the emails.
+//
+// They are signed like the JWT but nothing is stored, verifying an email
+// twice does no harm. The email is part of the token, so the tokens sent to
+// an old email of the user can't verify the new one.
+type verificationClaim struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// Audience of the verification tokens, it's not the audience of the JWT so
+// they can't be used to authenticate
+func verificationAudience() string {
+	return options.Audience + "/verify-email"
+}
+
+// Generates a token that verifies the current email of the user and returns
+// it with its expiration date.
+func IssueVerificationToken(user models.User) (string, time.Time, error) {
+	now := time.Now()
+	claim := verificationClaim{
+		Email: user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(user.Id, 10),
+			Issuer:    options.Issuer,
+			Audience:  jwt.ClaimStrings{verificationAudience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(options.VerificationTokenDuration)),
+		},
+	}
+
+	token, err := signToken(claim)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, claim.ExpiresAt.Time, nil
+}
+
+// Returns the id of the user and the email that the token verifies.
+func ValidateVerificationToken(t string) (int64, string, error) {
+	token, err := parser.ParseWithClaims(t, &verificationClaim{}, verifyFunction)
+	if err != nil || !token.Valid {
+		return 0, "", ErrVerificationTokenInvalid
+	}
+
+	claim, ok := token.Claims.(*verificationClaim)
+	if !ok || claim.Issuer != options.Issuer || !claim.VerifyAudience(verificationAudience(), true) || claim.Email == "" {
+		return 0, "", ErrVerificationTokenInvalid
+	}
+
+	userId, err := strconv.ParseInt(claim.Subject, 10, 64)
+	if err != nil {
+		return 0, "", ErrVerificationTokenInvalid
+	}
+
+	if claim.ExpiresAt == nil || time.Now().After(claim.ExpiresAt.Add(options.Leeway)) {
+		return 0, "", ErrVerificationTokenExpired
+	}
+
+	return userId, claim.Email, nil
+}
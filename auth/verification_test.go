@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// Verify that the verification token carries the user and the email.
+func TestVerificationToken(t *testing.T) {
+	setupTestToken(t)
+
+	token, expiresAt, err := IssueVerificationToken(models.User{Id: 42, Email: "ramiro@example.com"})
+	if err != nil {
+		t.Fatalf("❌ Could not issue the verification token: %v", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		t.Errorf("❌ The token is already expired: %v", expiresAt)
+	}
+
+	userId, email, err := ValidateVerificationToken(token)
+	if err != nil || userId != 42 || email != "ramiro@example.com" {
+		t.Errorf("❌ Expected user 42 and ramiro@example.com, got %d %s %v", userId, email, err)
+	} else {
+		t.Log("✅ Verification token validated.")
+	}
+}
+
+// Verify that the verification tokens and the JWT can't be used for each other.
+func TestVerificationTokenIsNotAnAccessToken(t *testing.T) {
+	setupTestToken(t)
+
+	token, _, _ := IssueVerificationToken(models.User{Id: 42, Email: "ramiro@example.com"})
+	if _, err := ValidateToken(context.Background(), token); err == nil {
+		t.Errorf("❌ A verification token was accepted as a JWT")
+	}
+
+	jwt, _ := GenerateToken(context.Background(), models.User{Id: 42, Username: "ramiro"})
+	if _, _, err := ValidateVerificationToken(jwt); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Errorf("❌ A JWT was accepted as a verification token: %v", err)
+	} else {
+		t.Log("✅ Verification tokens and JWT are not interchangeable.")
+	}
+}
+
+// Verify that an expired verification token is rejected.
+func TestVerificationTokenExpired(t *testing.T) {
+	setupTestToken(t)
+
+	options.VerificationTokenDuration = -time.Hour
+	token, _, _ := IssueVerificationToken(models.User{Id: 42, Email: "ramiro@example.com"})
+	options = DefaultOptions()
+
+	if _, _, err := ValidateVerificationToken(token); !errors.Is(err, ErrVerificationTokenExpired) {
+		t.Errorf("❌ Expected the token to be expired, got: %v", err)
+	} else {
+		t.Log("✅ Expired verification token rejected.")
+	}
+}
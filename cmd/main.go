@@ -1,31 +1,216 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
 	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/health"
 	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/mail"
+	"github.com/RamiroCuenca/go-jwt-auth/common/metrics"
+	"github.com/RamiroCuenca/go-jwt-auth/common/tracing"
+	"github.com/RamiroCuenca/go-jwt-auth/config"
 	"github.com/RamiroCuenca/go-jwt-auth/database/connection"
+	"github.com/RamiroCuenca/go-jwt-auth/database/migrations"
+	usersControllers "github.com/RamiroCuenca/go-jwt-auth/users/controllers"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
 )
 
 func main() {
+	// 1° Load the config: defaults, then the config file (-config flag or
+	// CONFIG_FILE) and then the environment variables
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "Path of the config file (.yaml, .yml or .toml)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile, os.Getenv)
+	if err != nil {
+		// The logger is not ready yet
+		log.Fatal(err)
+	}
+
 	// Init zap logger
-	logger.InitZapLogger()
+	if err := logger.Init(cfg.Log.Logger()); err != nil {
+		log.Fatal(err)
+	}
+
+	// The migrate subcommand manages the schema of the database and exits
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg.Database, flag.Args()[1:]); err != nil {
+			logger.Log().Fatal(err)
+		}
+		return
+	}
+
+	// Init the tracing, the pending spans are flushed when the server stops
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing.Tracing())
+	if err != nil {
+		logger.Log().Fatalf("Could not init the tracing. Error: %v", err)
+	}
 
 	// Parse the certificates/keys (JWT)
-	err := auth.LoadCertificates("certificates/app.rsa", "certificates/app.rsa.pub")
+	err = auth.LoadKeys(cfg.Auth.KeysDir, cfg.Auth.ActiveKid, cfg.Auth.Algorithm)
 	if err != nil {
 		logger.Log().Fatalf("Could not load the certificates/keys. Error: %v", err)
 	}
 
-	// Init postgre database
-	connection.NewPostgresClient()
+	// Setup the claims of the tokens
+	err = auth.Configure(cfg.Auth.Options())
+	if err != nil {
+		logger.Log().Fatalf("Invalid token options. Error: %v", err)
+	}
+
+	// Emails of the users (Verification links and reset tokens)
+	sender, err := mail.New(cfg.Mail.Mail())
+	if err != nil {
+		logger.Log().Fatalf("Could not create the mail sender. Error: %v", err)
+	}
+	usersControllers.SetMailer(sender)
+	usersControllers.SetEmailVerification(cfg.Auth.EmailVerification, cfg.Server.PublicURL)
+	usersControllers.SetTrustedProxies(cfg.Server.Proxies())
+
+	// Reload the keys on SIGHUP, or periodically, so that they can be rotated
+	auth.WatchKeys(time.Duration(cfg.Auth.KeysReloadInterval))
+
+	// Checks of /readyz, the storage adds its own
+	checker := health.NewChecker(time.Duration(cfg.Server.HealthCheckTimeout))
+	checker.Add("keys", auth.CheckKeys)
+
+	// Init the storage (Postgres by default), see setupStorage
+	closeStorage := setupStorage(cfg.Database, checker)
 
 	// Get the router
-	mux := Routes()
+	mux := Routes(checker)
+
+	// Setup the server, the database is closed once the requests are drained
+	sv := NewServer(mux, cfg.Server)
+	sv.OnShutdown(closeStorage)
+	sv.OnShutdown(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Log().Errorf("Could not flush the spans. Reason: %v", err)
+		}
+	})
+	// Registered last so that it's called first, the emails that are still
+	// being sent need the storage and their spans are flushed after
+	sv.OnShutdown(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+		defer cancel()
+
+		if err := usersControllers.WaitBackground(ctx); err != nil {
+			logger.Log().Errorf("Some emails were not sent before the shutdown. Reason: %v", err)
+		}
+	})
 
-	// Setup the server
-	sv := NewServer(mux)
+	// Run the server until it receives a SIGINT/SIGTERM
+	if err := sv.Run(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// Creates the repositories and stores according to the driver:
+//   - postgres (Default): everything is stored on the postgres database.
+//   - sqlite: users are stored on the SQLite file of database.sqlite_path,
+//     refresh tokens, reset tokens and revocations are kept in memory.
+//   - memory: everything is kept in memory, it's lost when the app stops.
+//
+// The checks of the database are added to the checker. It returns the function
+// that stops the sync of the revoked tokens and closes the database, it must
+// be called when the app stops.
+func setupStorage(c config.Database, checker *health.Checker) func() {
+	var refreshStore auth.RefreshStore
+	var resetStore auth.ResetStore
+	var revocationBackend auth.RevocationBackend
+	closeStorage := func() {}
+
+	switch c.Driver {
+	case "postgres":
+		// Init the pool of connections to the postgre database, it's shared
+		// by the repository and the stores
+		db, err := connection.NewPostgresClient(c.Postgres())
+		if err != nil {
+			logger.Log().Fatalf("Could not open the postgres database. Error: %v", err)
+		}
+		closeStorage = func() { db.Close() }
+
+		// A fresh database gets its schema before the first request
+		migrator, err := migrations.NewMigrator(db.DB)
+		if err != nil {
+			logger.Log().Fatalf("Could not load the migrations. Error: %v", err)
+		}
+		if c.MigrateOnStart {
+			migrateOnStart(migrator)
+		}
+
+		checker.Add("database", db.PingContext)
+		checker.Add("migrations", migrator.CheckCurrent)
+		metrics.RegisterDB("postgres", db.DB)
+
+		usersControllers.SetRepository(repository.WithTracing(repository.NewPostgresRepository(db.DB), "postgresql"))
+		refreshStore = auth.NewPostgresRefreshStore(db.DB)
+		resetStore = auth.NewPostgresResetStore(db.DB)
+		revocationBackend = auth.NewPostgresRevocationBackend(db.DB)
+
+	case "sqlite":
+		db, err := connection.NewSQLiteClient(c.SQLitePath)
+		if err != nil {
+			logger.Log().Fatalf("Could not open the SQLite database. Error: %v", err)
+		}
+
+		repo, err := repository.NewSQLiteRepository(db)
+		if err != nil {
+			logger.Log().Fatalf("Could not create the SQLite schema. Error: %v", err)
+		}
+
+		usersControllers.SetRepository(repository.WithTracing(repo, "sqlite"))
+		closeStorage = func() { db.Close() }
+		checker.Add("database", db.PingContext)
+		metrics.RegisterDB("sqlite", db)
+		refreshStore = auth.NewMemoryRefreshStore()
+		resetStore = auth.NewMemoryResetStore()
+		revocationBackend = auth.NewMemoryRevocationBackend()
+
+	case "memory":
+		usersControllers.SetRepository(repository.WithTracing(repository.NewMemoryRepository(), "memory"))
+		refreshStore = auth.NewMemoryRefreshStore()
+		resetStore = auth.NewMemoryResetStore()
+		revocationBackend = auth.NewMemoryRevocationBackend()
+
+	default:
+		logger.Log().Fatalf("Unknown database driver %s, use postgres, sqlite or memory", c.Driver)
+	}
+
+	// Refresh tokens are stored on the database
+	auth.SetRefreshStore(refreshStore)
+	auth.SetResetStore(resetStore)
+
+	// Revoked tokens are stored on the database and cached in memory
+	revocations := auth.NewRevocationList(revocationBackend)
+	if err := revocations.Sync(); err != nil {
+		logger.Log().Errorf("Could not load the revoked tokens. Reason: %v", err)
+	}
+	stopSync := revocations.StartSync(time.Minute)
+	auth.SetRevocationList(revocations)
+
+	// The sync must stop before the database is closed
+	return func() {
+		stopSync()
+		closeStorage()
+	}
+}
+
+// Applies the pending migrations, the app can't work with an old schema so it
+// stops if they fail.
+func migrateOnStart(migrator *migrations.Migrator) {
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		logger.Log().Fatalf("Could not apply the migrations. Error: %v", err)
+	}
 
-	// Run the server
-	logger.Log().Info("Server running over port :8000 ...\n")
-	sv.Run()
+	logger.Log().Infof("Database schema up to date, %d migrations applied", applied)
 }
@@ -3,22 +3,54 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/RamiroCuenca/go-jwt-auth/auth"
-	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	usersControllers "github.com/RamiroCuenca/go-jwt-auth/users/controllers"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
 )
 
 // I'm sure that there are some provided by the community
 
-// It's receives and returns a handler
+// It's receives and returns a handler.
+//
+// The claim of the validated token is stored on the request context so that
+// the next handlers can know who is the user (See auth.ClaimFromContext).
 func AuthenticationMiddleware(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		token := r.Header.Get("Authorization")
-		_, err := auth.ValidateToken(token) // auth is the package we created
+		claim, err := auth.ValidateToken(r.Context(), token) // auth is the package we created
 		// If token is invalid
 		if err != nil {
-			forbidden(w, r)
+			unauthorized(w, r, err)
+			return
+		}
+
+		// The logs of the request (And its access log) tell who sent it
+		logger.AddFields(r.Context(), "user_id", claim.UserId())
+
+		f(w, r.WithContext(auth.NewContext(r.Context(), claim)))
+	}
+}
+
+// Only lets through the users that have one of the roles.
+//
+// It must be wrapped by AuthenticationMiddleware.
+func RoleMiddleware(f func(w http.ResponseWriter, r *http.Request), roles ...string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claim, ok := auth.ClaimFromContext(r.Context())
+		if !ok {
+			unauthorized(w, r, errors.New("Missing claim, RoleMiddleware must be wrapped by AuthenticationMiddleware"))
+			return
+		}
+
+		if !hasRole(claim, roles) {
+			notAllowed(w, r)
 			return
 		}
 
@@ -26,9 +58,61 @@ func AuthenticationMiddleware(f func(w http.ResponseWriter, r *http.Request)) fu
 	}
 }
 
-func forbidden(w http.ResponseWriter, r *http.Request) {
-	json := []byte(`{
-	"message": "It hasn't got authorization"
-}`)
-	handler.SendError(w, http.StatusForbidden, json)
+// Only lets through the users that verified their email, unless the email
+// verification is off (See usersControllers.SetEmailVerification).
+//
+// It must be wrapped by AuthenticationMiddleware.
+func VerifiedEmailMiddleware(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claim, ok := auth.ClaimFromContext(r.Context())
+		if !ok {
+			unauthorized(w, r, errors.New("Missing claim, VerifiedEmailMiddleware must be wrapped by AuthenticationMiddleware"))
+			return
+		}
+
+		if usersControllers.VerifiedEmailRequired() && claim.Unverified() {
+			problem.Write(w, r, problem.New(http.StatusForbidden, problem.CodeEmailNotVerified,
+				"The email is not verified, open the link that was sent to it"))
+			return
+		}
+
+		f(w, r)
+	}
+}
+
+// Marks the routes as deprecated since deprecation, they will be removed
+// on sunset and successor is the route that replaces them.
+//
+// The clients are told with the Deprecation (RFC 9745), Sunset (RFC 8594)
+// and Link headers.
+func DeprecationMiddleware(deprecation, sunset time.Time, successor string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecation.Unix()))
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Checks if the claim has one of the roles
+func hasRole(claim models.Claim, roles []string) bool {
+	for _, role := range roles {
+		if claim.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The token is missing or not valid, err (Only logged) tells why
+func unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Write(w, r, problem.Wrap(err, http.StatusUnauthorized, problem.CodeUnauthorized, "It hasn't got authorization"))
+}
+
+func notAllowed(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, problem.New(http.StatusForbidden, problem.CodeForbidden, "It hasn't got permission to access this resource"))
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/RamiroCuenca/go-jwt-auth/config"
+	"github.com/RamiroCuenca/go-jwt-auth/database/connection"
+	"github.com/RamiroCuenca/go-jwt-auth/database/migrations"
+)
+
+// Runs the migrate subcommand instead of the server:
+//   - migrate up: applies every pending migration.
+//   - migrate down [steps]: reverts the last steps migrations (1 by default).
+//   - migrate status: lists the migrations and if they are applied.
+func runMigrate(c config.Database, args []string) error {
+	if c.Driver != "postgres" {
+		return fmt.Errorf("The migrations are only used by postgres, the database driver is %s", c.Driver)
+	}
+	if len(args) == 0 {
+		return errors.New("Usage: migrate up | down [steps] | status")
+	}
+
+	db, err := connection.NewPostgresClient(c.Postgres())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db.DB)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migrations applied\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New("The steps must be a number greater than 0")
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migrations reverted\n", reverted)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%06d_%s\t%s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		return fmt.Errorf("Unknown migrate command %s, use up, down or status", args[0])
+	}
+
+	return nil
+}
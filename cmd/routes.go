@@ -1,29 +1,109 @@
 package main
 
 import (
+	"net/http"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/health"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/metrics"
+	"github.com/RamiroCuenca/go-jwt-auth/common/tracing"
 	usersControllers "github.com/RamiroCuenca/go-jwt-auth/users/controllers"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
+)
+
+// The users routes of v1 were deprecated on v1Deprecation and are removed
+// after v1Sunset (See DeprecationMiddleware)
+var (
+	v1Deprecation = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	v1Sunset      = time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC)
 )
 
 // Return a multiplexor with all the app routes
-func Routes() *chi.Mux {
+//
+// The checker runs the checks of /readyz.
+func Routes(checker *health.Checker) *chi.Mux {
 	// Create a new multiplexor
 	r := chi.NewMux()
 
-	// We are going to use logger middleware from chi
-	r.Use(middleware.Logger)
+	// Every request has an id (X-Request-ID) and its own logger
+	r.Use(logger.RequestIDMiddleware)
+
+	// Start the span of every request (W3C traceparent is propagated)
+	r.Use(tracing.Middleware)
+
+	// Log every request with zap
+	r.Use(logger.AccessLogMiddleware)
+
+	// Count the requests and their latency, by route
+	r.Use(metrics.Middleware)
+
+	// Health of the app, used by the orchestrator
+	r.Get("/healthz", health.LivenessHandler)
+	r.Get("/readyz", checker.ReadinessHandler)
+
+	// Prometheus metrics
+	r.Method("GET", "/metrics", metrics.Handler())
+
+	// Public keys used to verify the JWT
+	r.Get("/.well-known/jwks.json", auth.JWKSHandler)
 
 	// Path prefix
 	pp := "/api/v1"
 
+	// Authenticated routes that also require a verified email, the users that
+	// did not verify it can only access their account (See VerifiedEmailMiddleware)
+	verified := func(f func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+		return AuthenticationMiddleware(VerifiedEmailMiddleware(f))
+	}
+
 	// Auth routes
-	r.Post(pp+"/register", usersControllers.SignUp)
 	r.Post(pp+"/login", usersControllers.SignIn)
-	r.Get(pp+"/readall", AuthenticationMiddleware(usersControllers.ReadAll))
-	r.Get(pp+"/readbyid", AuthenticationMiddleware(usersControllers.ReadById))
-	r.Put(pp+"/updatebyid", AuthenticationMiddleware(usersControllers.UpdateById))
-	r.Delete(pp+"/deletebyid", AuthenticationMiddleware(usersControllers.DeleteById))
+	r.Post(pp+"/token/refresh", usersControllers.RefreshToken)
+	r.Post(pp+"/logout", AuthenticationMiddleware(usersControllers.Logout))
+
+	// Forgotten password, the token sent by email sets a new one
+	r.Post(pp+"/password/forgot", usersControllers.ForgotPassword)
+	r.Post(pp+"/password/reset", usersControllers.ResetPassword)
+
+	// Verification of the email, the link is sent on the sign up
+	r.Get(pp+"/verify-email", usersControllers.VerifyEmail)
+	r.Post(pp+"/verify-email/resend", usersControllers.ResendVerification)
+
+	// Users routes of v1, replaced by the /api/v2/users resource. They keep
+	// working until the sunset date
+	r.Group(func(r chi.Router) {
+		r.Use(DeprecationMiddleware(v1Deprecation, v1Sunset, "/api/v2/users"))
+
+		r.Post(pp+"/register", usersControllers.SignUp)
+		r.Get(pp+"/readall", verified(RoleMiddleware(usersControllers.ReadAll, models.RoleAdmin)))
+		r.Get(pp+"/readbyid", verified(usersControllers.ReadById))
+		r.Put(pp+"/updatebyid", verified(usersControllers.UpdateById))
+		r.Delete(pp+"/deletebyid", verified(usersControllers.DeleteById))
+	})
+
+	// Account of the authenticated user
+	r.Post(pp+"/users/me/password", AuthenticationMiddleware(usersControllers.ChangePassword))
+
+	// Users resource, regular users can only access their own record
+	r.Route("/api/v2/users", func(r chi.Router) {
+		r.Get("/", verified(RoleMiddleware(usersControllers.ReadAll, models.RoleAdmin)))
+		r.Post("/", usersControllers.SignUp)
+		r.Get("/me", AuthenticationMiddleware(usersControllers.ReadMe))
+		r.Get("/{id}", verified(usersControllers.ReadById))
+		r.Patch("/{id}", verified(usersControllers.PatchUser))
+		r.Delete("/{id}", verified(usersControllers.DeleteById))
+	})
+
+	// Admin routes
+	r.Post(pp+"/admin/revoketokens", verified(RoleMiddleware(usersControllers.RevokeUserTokens, models.RoleAdmin)))
+
+	// GET returns the level of the logs and PUT changes it, e.g. {"level": "debug"}
+	loglevel := verified(RoleMiddleware(logger.LevelHandler().ServeHTTP, models.RoleAdmin))
+	r.Get(pp+"/admin/loglevel", loglevel)
+	r.Put(pp+"/admin/loglevel", loglevel)
 
 	return r
 }
@@ -1,37 +1,102 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/config"
 	"github.com/go-chi/chi"
 )
 
 // We create MyServer struct in order to add the run method to it
 type MyServer struct {
 	server *http.Server
+	// Maximum time to wait for the in-flight requests when stopping
+	shutdownTimeout time.Duration
+	// Functions that release the resources once the requests are drained
+	closers []func()
 }
 
 // This function sets up the server configuration and returns the same.
-// It receives as a parameter the multiplexer (In this case from chi).
-func NewServer(mux *chi.Mux) *MyServer {
+// It receives as a parameter the multiplexer (In this case from chi) and the
+// config of the server.
+func NewServer(mux *chi.Mux, c config.Server) *MyServer {
 	s := &http.Server{
-		Addr:           ":8000",
+		Addr:           c.Address,
 		Handler:        mux,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+		ReadTimeout:    time.Duration(c.ReadTimeout),
+		WriteTimeout:   time.Duration(c.WriteTimeout),
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	return &MyServer{s}
+	return &MyServer{server: s, shutdownTimeout: time.Duration(c.ShutdownTimeout)}
+}
+
+// Registers a function that is called when the server stops, after the
+// in-flight requests are drained (e.g. closing the database).
+// They are called in the reverse order that they were registered.
+func (s *MyServer) OnShutdown(f func()) {
+	s.closers = append(s.closers, f)
 }
 
 // Run works as a method of MyServer struct an it function is to
 // run the server
-func (s *MyServer) Run() {
-	// By default, the method ListenAndServe() run just for a period
-	// of miliseconds, so... envolving it with the method Fatal (Of the logger)
-	// we make sure that it will run at least until it get's an error.
-	logger.Log().Fatal(s.server.ListenAndServe())
+//
+// It blocks until the server fails or a SIGINT/SIGTERM is received, then:
+// 1° Stops accepting new connections.
+// 2° Waits for the in-flight requests, at most the shutdown timeout.
+// 3° Calls the OnShutdown functions.
+// 4° Flushes the logs.
+//
+// It returns nil if the server stopped cleanly.
+func (s *MyServer) Run() error {
+	// Listen for the signals before starting, so that none is missed
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	failed := make(chan error, 1)
+	go func() {
+		logger.Log().Infof("Server running over %s ...", s.server.Addr)
+		failed <- s.server.ListenAndServe()
+	}()
+
+	var err error
+
+	select {
+	// The server could not start (e.g. the port is in use)
+	case err = <-failed:
+
+	case sig := <-stop:
+		logger.Log().Infof("Received %s, shutting down...", sig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+		defer cancel()
+
+		err = s.server.Shutdown(ctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Log().Errorf("Some requests did not finish after %s, closing them", s.shutdownTimeout)
+			s.server.Close()
+		}
+	}
+
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+
+	if err != nil {
+		logger.Log().Errorf("Server stopped with error: %v", err)
+	} else {
+		logger.Log().Info("Server stopped")
+	}
+
+	logger.Sync()
+
+	return err
 }
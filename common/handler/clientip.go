@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies are the reverse proxies and load balancers in front of the
+// app, only they can tell the address of the client with X-Forwarded-For.
+type TrustedProxies []*net.IPNet
+
+// Parses the addresses (e.g. 10.0.0.1) and ranges (e.g. 10.0.0.0/8) of the
+// trusted proxies.
+func ParseTrustedProxies(proxies []string) (TrustedProxies, error) {
+	result := TrustedProxies{}
+
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		// A single address is a range of one
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid trusted proxy %s, use an ip or a range like 10.0.0.0/8", p)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy %s, use an ip or a range like 10.0.0.0/8", p)
+		}
+		result = append(result, ipNet)
+	}
+
+	return result, nil
+}
+
+// Checks if the address belongs to a trusted proxy
+func (t TrustedProxies) contains(ip net.IP) bool {
+	for _, n := range t {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the ip of the client that sent the request.
+//
+// It's the address of the connection, unless it comes from a trusted proxy.
+// Then X-Forwarded-For is read from the right, as every proxy appends the
+// address it received the request from, and the first address that is not a
+// trusted proxy is the client. The addresses on its left are sent by the
+// client, so they can't be trusted.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !t.contains(ip) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		next := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if next == nil {
+			// Not sent by a trusted proxy, the last valid address is used
+			break
+		}
+
+		ip = next
+		if !t.contains(ip) {
+			break
+		}
+	}
+
+	return ip.String()
+}
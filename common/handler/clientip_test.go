@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// Verify that X-Forwarded-For is only read from the trusted proxies.
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("❌ Could not parse the proxies: %v", err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		// Direct clients can't choose their address
+		{"203.0.113.7:5000", nil, "203.0.113.7"},
+		{"203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		// Behind a proxy, the address that it appended
+		{"10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		// The addresses sent by the client are ignored
+		{"10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		// A chain of trusted proxies, in one or several headers
+		{"10.0.0.2:5000", []string{"198.51.100.1, 192.168.1.1", "10.0.0.3"}, "198.51.100.1"},
+		// Garbage appended by the client
+		{"10.0.0.2:5000", []string{"not-an-ip, 10.0.0.3"}, "10.0.0.3"},
+		// No header, the proxy itself
+		{"192.168.1.1:5000", nil, "192.168.1.1"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for _, f := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", f)
+		}
+
+		if got := proxies.ClientIP(r); got != tt.expected {
+			t.Errorf("❌ %s %v: expected %s, got %s", tt.remoteAddr, tt.forwarded, tt.expected, got)
+		}
+	}
+
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Errorf("❌ Expected the invalid range to be rejected")
+	} else {
+		t.Log("✅ Client ip read from the trusted proxies.")
+	}
+}
@@ -14,13 +14,3 @@ func SendResponse(w http.ResponseWriter, status int, data []byte, token string)
 	w.Write(data)
 
 }
-
-// Common function wich will be used to send errors for incorrect http requests
-func SendError(w http.ResponseWriter, status int, data []byte) {
-
-	// data := []byte(`{}`)
-
-	w.Header().Set("Content-Type", "application/")
-	w.WriteHeader(status)
-	w.Write(data)
-}
@@ -0,0 +1,139 @@
+// Package health provides the endpoints used by the orchestrator to know if
+// the app is alive (/healthz) and ready to receive traffic (/readyz).
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+)
+
+// Check returns nil if the dependency is working.
+//
+// It must return once ctx is done, every check has its own timeout.
+type Check func(ctx context.Context) error
+
+// Result of a check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Runs the readiness checks.
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// Returns a Checker that gives every check at most timeout to finish.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, checks: map[string]Check{}}
+}
+
+// Adds a check, name is the key of its result on the response.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// Runs every check at the same time and returns their results, ready is
+// false if any of them failed.
+func (c *Checker) Run(ctx context.Context) (ready bool, results map[string]Result) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results = map[string]Result{}
+	ready = true
+
+	for name, check := range c.checks {
+		wg.Add(1)
+
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if result.Error != "" {
+				ready = false
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return ready, results
+}
+
+// Runs a single check with the timeout
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	// The check runs on its own goroutine so that one that ignores the
+	// context can't block the response
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: "ok", Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// GET /readyz
+//
+// Responds 200 if every check passed or 503 if not, with the result of each
+// one so that the reason is easy to find.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	ready, results := c.Run(r.Context())
+
+	status := "ok"
+	code := http.StatusOK
+	if !ready {
+		status = "unavailable"
+		code = http.StatusServiceUnavailable
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+
+	w.Header().Set("Cache-Control", "no-store")
+	handler.SendResponse(w, code, body, "")
+}
+
+// GET /healthz
+//
+// The process is alive if it can answer, it does not check the dependencies
+// so that a database outage does not get every replica restarted.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	handler.SendResponse(w, http.StatusOK, []byte(`{"status": "ok"}`), "")
+}
@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Verify that /readyz reports every check and fails if one of them fails or is too slow.
+func TestReadiness(t *testing.T) {
+	checker := NewChecker(time.Millisecond * 50)
+	checker.Add("ok", func(ctx context.Context) error { return nil })
+	checker.Add("broken", func(ctx context.Context) error { return errors.New("connection refused") })
+	checker.Add("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	checker.ReadinessHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("❌ Expected 503, got %d", w.Code)
+	}
+
+	body := struct {
+		Status string
+		Checks map[string]Result
+	}{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("❌ Could not decode the response: %v", err)
+	}
+
+	if body.Checks["ok"].Status != "ok" || body.Checks["broken"].Error != "connection refused" {
+		t.Errorf("❌ Unexpected results %+v", body.Checks)
+	}
+	if body.Checks["slow"].Status != "error" {
+		t.Errorf("❌ Expected the slow check to time out, got %+v", body.Checks["slow"])
+	} else {
+		t.Log("✅ Readiness reports every check.")
+	}
+}
+
+// Verify that /readyz responds 200 once every check passes.
+func TestReadinessOk(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Add("ok", func(ctx context.Context) error { return nil })
+
+	w := httptest.NewRecorder()
+	checker.ReadinessHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("❌ Expected 200, got %d", w.Code)
+	} else {
+		t.Log("✅ Ready.")
+	}
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type contextKey struct{}
+
+// Logger of a request, the fields added while the request is handled (e.g. the
+// user id once the token is validated) are seen by every one that shares it.
+type requestLogger struct {
+	mu     sync.Mutex
+	logger *zap.SugaredLogger
+}
+
+// Returns a copy of the context that carries the logger.
+func NewContext(ctx context.Context, l *zap.SugaredLogger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestLogger{logger: l})
+}
+
+// Returns the logger of the request, with its request id and the fields
+// added with AddFields. If there is none, the logger of the app is returned.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	rl, ok := ctx.Value(contextKey{}).(*requestLogger)
+	if !ok {
+		return Log()
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.logger
+}
+
+// Adds the key-value pairs to the logger of the request, including the access
+// log that is written once the request finishes.
+func AddFields(ctx context.Context, keysAndValues ...interface{}) {
+	rl, ok := ctx.Value(contextKey{}).(*requestLogger)
+	if !ok {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.logger = rl.logger.With(keysAndValues...)
+}
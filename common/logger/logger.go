@@ -1,23 +1,122 @@
 package logger
 
 import (
-	"log"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var sugar *zap.SugaredLogger
 
-// This function inits the zap logger.
-// It may be called from the main package at the start of the application.
+// Level of the logger, it can be changed while the app runs (See LevelHandler).
+var level = zap.NewAtomicLevel()
+
+// Config of the logger.
+type Config struct {
+	// json or console, the human friendly output. If it's empty the console
+	// is used on development and json otherwise.
+	Format string
+	// Minimum level logged: debug, info, warn or error.
+	Level string
+	// Development adds the stack traces to the warnings and panics on DPanic.
+	Development bool
+
+	// After SamplingInitial equal logs in a second, only one of every
+	// SamplingThereafter is written. 0 disables the sampling.
+	SamplingInitial    int
+	SamplingThereafter int
+
+	// File where the logs are written, stderr if it's empty. The file is
+	// rotated once it reaches MaxSizeMB.
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
+}
+
+// Returns the config for production: JSON logs at info level, sampled.
+func DefaultConfig() Config {
+	return Config{
+		Format:             "json",
+		Level:              "info",
+		SamplingInitial:    100,
+		SamplingThereafter: 100,
+		MaxSizeMB:          100,
+		MaxBackups:         10,
+		MaxAgeDays:         30,
+	}
+}
+
+// This function inits the zap logger for development (Console output at
+// debug level), it's used by the tests.
 func InitZapLogger() error {
-	l, err := zap.NewDevelopment()
-	if err != nil {
-		log.Printf("Could not init logger: %v", err)
-		return err
+	return Init(Config{Format: "console", Level: "debug", Development: true})
+}
+
+// Inits the zap logger with the config.
+// It may be called from the main package at the start of the application.
+func Init(c Config) error {
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return fmt.Errorf("Invalid log level %s", c.Level)
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	format := c.Format
+	if format == "" {
+		format = "json"
+		if c.Development {
+			format = "console"
+		}
+	}
+
+	var encoder zapcore.Encoder
+	switch format {
+	case "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return fmt.Errorf("Invalid log format %s, use json or console", c.Format)
+	}
+
+	// stderr or the file, rotated by lumberjack
+	output := zapcore.Lock(os.Stderr)
+	if c.File != "" {
+		output = zapcore.AddSync(&lumberjack.Logger{
+			Filename:   c.File,
+			MaxSize:    c.MaxSizeMB,
+			MaxBackups: c.MaxBackups,
+			MaxAge:     c.MaxAgeDays,
+			Compress:   c.Compress,
+		})
+	}
+
+	core := zapcore.NewCore(encoder, output, level)
+
+	if c.SamplingInitial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, c.SamplingInitial, c.SamplingThereafter)
+	}
+
+	// The sensitive fields are removed before they reach the output
+	core = redactCore{core}
+
+	options := []zap.Option{zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(os.Stderr))}
+	if c.Development {
+		options = append(options, zap.Development(), zap.AddStacktrace(zapcore.WarnLevel))
+	} else {
+		options = append(options, zap.AddStacktrace(zapcore.ErrorLevel))
 	}
 
-	sugar = l.Sugar()
+	sugar = zap.New(core, options...).Sugar()
 
 	return nil
 }
@@ -28,3 +127,25 @@ func InitZapLogger() error {
 func Log() *zap.SugaredLogger {
 	return sugar
 }
+
+// GET returns the current level and PUT changes it, e.g. {"level": "debug"}.
+//
+// It changes the level of the running app, it must only be reachable by admins.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		previous := level.Level()
+		level.ServeHTTP(w, r)
+
+		if current := level.Level(); current != previous {
+			FromContext(r.Context()).Warnw("Log level changed", "from", previous.String(), "to", current.String())
+		}
+	})
+}
+
+// Flushes the buffered logs, it must be called before the app exits.
+func Sync() {
+	if sugar != nil {
+		// Syncing stderr fails on some systems, there's nothing to do about it
+		_ = sugar.Sync()
+	}
+}
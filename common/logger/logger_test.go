@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Verify that the logs are written as JSON on the file and that the level
+// can be changed while the app runs.
+func TestInitFileAndLevel(t *testing.T) {
+	defer InitZapLogger()
+
+	c := DefaultConfig()
+	c.File = filepath.Join(t.TempDir(), "app.log")
+
+	if err := Init(c); err != nil {
+		t.Fatalf("❌ %v", err)
+	}
+
+	Log().Debug("Hidden")
+	Log().Infow("Visible", "token", "abc")
+
+	req := httptest.NewRequest("PUT", "/", strings.NewReader(`{"level": "debug"}`))
+	rec := httptest.NewRecorder()
+	LevelHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("❌ Expected 200 changing the level, got %d", rec.Code)
+	}
+
+	Log().Debug("Debugging")
+	Sync()
+
+	content, err := os.ReadFile(c.File)
+	if err != nil {
+		t.Fatalf("❌ %v", err)
+	}
+	logs := string(content)
+
+	switch {
+	case strings.Contains(logs, "Hidden"):
+		t.Errorf("❌ A debug log was written at info level")
+	case !strings.Contains(logs, `"msg":"Visible"`), !strings.Contains(logs, "Debugging"):
+		t.Errorf("❌ Missing logs on the file: %s", logs)
+	case strings.Contains(logs, "abc"):
+		t.Errorf("❌ The token was written on the file")
+	default:
+		t.Log("✅ Logs written on the file and level changed.")
+	}
+}
+
+// Verify that the repeated logs are sampled.
+func TestInitSampling(t *testing.T) {
+	defer InitZapLogger()
+
+	c := DefaultConfig()
+	c.File = filepath.Join(t.TempDir(), "app.log")
+	c.Level = "info"
+	c.SamplingInitial = 2
+	c.SamplingThereafter = 1000
+
+	if err := Init(c); err != nil {
+		t.Fatalf("❌ %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		Log().Infow("Repeated", "token", "abc")
+	}
+	Sync()
+
+	content, err := os.ReadFile(c.File)
+	if err != nil {
+		t.Fatalf("❌ %v", err)
+	}
+
+	lines := strings.Count(string(content), "\n")
+	if lines != 2 {
+		t.Errorf("❌ Expected 2 of the 50 repeated logs, got %d", lines)
+	} else if strings.Contains(string(content), "abc") {
+		t.Errorf("❌ The sampled logs were not redacted: %s", content)
+	} else {
+		t.Log("✅ Repeated logs sampled.")
+	}
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header with the id of the request, it's read from the request and sent back
+// on the response.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// The ids received are only trusted if they are short and printable, so that
+// they can't be used to inject lines on the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:/-]{1,128}$`)
+
+// Returns the id of the request, empty if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Gives every request an id, the one of the X-Request-ID header if the caller
+// sent it or a new one. It's sent back on the response and every log of the
+// request carries it (See FromContext).
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = NewContext(ctx, Log().With("request_id", id))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Writes a line for every request with the method, route, status, latency,
+// request id and user id (If the request was authenticated).
+//
+// It must be used after RequestIDMiddleware.
+func AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Correlate the logs with the trace of the request
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			AddFields(r.Context(), "trace_id", sc.TraceID().String())
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		// Always at info level, the errors are logged by the handlers with
+		// their reason
+		FromContext(r.Context()).Infow("Request handled",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"latency", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// 16 random bytes, hex encoded
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
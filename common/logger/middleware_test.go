@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Verify that the access log has the fields of the request, including the ones
+// added by the handlers, and that the X-Request-ID received is honored.
+func TestAccessLog(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	sugar = zap.New(core).Sugar()
+
+	r := chi.NewMux()
+	r.Use(RequestIDMiddleware)
+	r.Use(AccessLogMiddleware)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		AddFields(r.Context(), "user_id", int64(42))
+		FromContext(r.Context()).Info("Fetching the user")
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Errorf("❌ Expected the request id to be sent back, got %s", w.Header().Get(RequestIDHeader))
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("❌ Expected 2 logs, got %d", len(entries))
+	}
+
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "abc-123" || fields["user_id"] != int64(42) {
+			t.Errorf("❌ The log %q is not correlated: %v", entry.Message, fields)
+		}
+	}
+
+	access := entries[1].ContextMap()
+	if access["route"] != "/users/{id}" || access["status"] != int64(http.StatusTeapot) {
+		t.Errorf("❌ Unexpected access log %v", access)
+	} else {
+		t.Log("✅ Access log written with the fields of the request.")
+	}
+}
+
+// Verify that an invalid X-Request-ID is replaced, so it can't inject lines on the logs.
+func TestInvalidRequestID(t *testing.T) {
+	sugar = zap.NewNop().Sugar()
+
+	h := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc\nfake log line")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if id := w.Header().Get(RequestIDHeader); id == "" || id == "abc\nfake log line" {
+		t.Errorf("❌ Expected a new request id, got %q", id)
+	} else {
+		t.Log("✅ Invalid request id replaced.")
+	}
+}
@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Text that replaces the sensitive values
+const redacted = "[REDACTED]"
+
+// Keys of the fields that are never logged (Lowercase, without "_" nor "-")
+var sensitiveKeys = map[string]bool{
+	"password":       true,
+	"hashedpassword": true,
+	"newpassword":    true,
+	"token":          true,
+	"jwt":            true,
+	"accesstoken":    true,
+	"refreshtoken":   true,
+	"resettoken":     true,
+	"authorization":  true,
+	"cookie":         true,
+	"setcookie":      true,
+	"secret":         true,
+	"apikey":         true,
+	"body":           true,
+}
+
+// Checks if the key of a field or header is sensitive
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	key = strings.NewReplacer("_", "", "-", "").Replace(key)
+
+	return sensitiveKeys[key]
+}
+
+// Core that redacts the sensitive fields before they reach the core that
+// writes them, so no output can leak a password or a token.
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	// The inner core decides if the entry is written (e.g. the sampler drops
+	// the repeated ones), but this core is registered instead of it so that
+	// Write is redacted
+	if c.Core.Check(entry, nil) == nil {
+		return ce
+	}
+
+	return ce.AddCore(entry, c)
+}
+
+func (c redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+// Returns the fields with the sensitive values replaced
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	result := make([]zapcore.Field, len(fields))
+
+	for i, f := range fields {
+		switch {
+		case isSensitive(f.Key):
+			result[i] = zap.String(f.Key, redacted)
+		case f.Type == zapcore.ReflectType || f.Type == zapcore.StringerType:
+			// The headers of a request, e.g. zap.Any("headers", r.Header)
+			if h, ok := f.Interface.(http.Header); ok {
+				result[i] = zap.Any(f.Key, redactHeader(h))
+				continue
+			}
+			result[i] = f
+		default:
+			result[i] = f
+		}
+	}
+
+	return result
+}
+
+// Returns a copy of the header with the sensitive values replaced
+func redactHeader(h http.Header) http.Header {
+	clone := h.Clone()
+
+	for key := range clone {
+		if isSensitive(key) {
+			clone[key] = []string{redacted}
+		}
+	}
+
+	return clone
+}
@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Verify that the passwords, tokens and Authorization headers never reach the output.
+func TestRedaction(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := zap.New(redactCore{core}).Sugar()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer eyJhbGciOi...")
+	header.Set("Accept", "application/json")
+
+	l.With("refresh_token", "abc").Infow("Login",
+		"email", "ramiro@example.com",
+		"password", "pass123",
+		"headers", header,
+	)
+
+	fields := logs.All()[0].ContextMap()
+
+	if fields["password"] != redacted || fields["refresh_token"] != redacted {
+		t.Errorf("❌ The password or token was logged: %v", fields)
+	}
+	if fields["email"] != "ramiro@example.com" {
+		t.Errorf("❌ A field that is not sensitive was redacted: %v", fields)
+	}
+
+	logged, _ := fields["headers"].(http.Header)
+	if logged.Get("Authorization") != redacted || logged.Get("Accept") != "application/json" {
+		t.Errorf("❌ Unexpected headers %v", logged)
+	} else if header.Get("Authorization") == redacted {
+		t.Errorf("❌ The header of the request was modified")
+	} else {
+		t.Log("✅ Sensitive fields redacted.")
+	}
+}
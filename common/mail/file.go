@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sender that appends the emails to a file, so that they can be read without
+// an SMTP server. It's meant for tests and local development.
+type fileSender struct {
+	path string
+	from string
+	mu   sync.Mutex
+}
+
+// Returns a Sender that appends the emails to the file, it's created if it
+// does not exist.
+func NewFileSender(path, from string) Sender {
+	return &fileSender{path: path, from: from}
+}
+
+func (s *fileSender) Send(ctx context.Context, m Message) error {
+	data, err := m.format(s.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// The emails are separated by a blank line
+	if _, err := f.Write(append(data, "\r\n\r\n"...)); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
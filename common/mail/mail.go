@@ -0,0 +1,101 @@
+// Package mail sends the emails of the app (e.g. the password reset tokens).
+//
+// The controllers only know the Sender interface, the main package picks the
+// implementation with New.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+)
+
+// Message is an email of plain text.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender sends the emails.
+type Sender interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Config of the sender.
+type Config struct {
+	// log, file or smtp.
+	Driver string
+	// Address the emails are sent from.
+	From string
+	// File where the emails are appended (file driver).
+	File string
+	// Server of the smtp driver, the credentials are optional.
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// Returns the sender of the driver:
+//   - log: the emails are only written on the logs (Local development).
+//   - file: the emails are appended to a file (Local development and tests).
+//   - smtp: the emails are sent through an SMTP server.
+func New(c Config) (Sender, error) {
+	switch c.Driver {
+	case "log":
+		return NewLogSender(), nil
+	case "file":
+		return NewFileSender(c.File, c.From), nil
+	case "smtp":
+		return NewSMTPSender(c)
+	default:
+		return nil, fmt.Errorf("Unknown mail driver %s, use log, file or smtp", c.Driver)
+	}
+}
+
+// Sender that writes the emails on the logs instead of sending them.
+//
+// Only the recipient and the subject are logged, the body has the reset tokens
+// and the verification links so it's never written. It's meant for tests and
+// local development, the file driver keeps the whole emails.
+type logSender struct{}
+
+// Returns a Sender that logs the emails.
+func NewLogSender() Sender {
+	return logSender{}
+}
+
+func (logSender) Send(ctx context.Context, m Message) error {
+	logger.FromContext(ctx).Infow("Email not sent, it's only logged", "to", m.To, "subject", m.Subject)
+	return nil
+}
+
+// Returns the message as an email (RFC 5322) sent by from.
+//
+// The headers can't have line breaks, otherwise a crafted address could add
+// its own headers (e.g. Bcc).
+func (m Message) format(from string, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, errors.New("The headers of the email can not have line breaks")
+		}
+	}
+
+	b := bytes.Buffer{}
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,128 @@
+package mail
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var message = Message{To: "ramiro@example.com", Subject: "Verify your email", Body: "Hi ramiro,\nthe token is abc\n"}
+
+// Verify that the emails are appended to the file.
+func TestFileSender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emails.txt")
+	s := NewFileSender(path, "no-reply@example.com")
+
+	for i := 0; i < 2; i++ {
+		if err := s.Send(context.Background(), message); err != nil {
+			t.Fatalf("❌ Could not send the email: %v", err)
+		}
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	content := string(data)
+
+	if strings.Count(content, "To: ramiro@example.com\r\n") != 2 || !strings.Contains(content, "From: no-reply@example.com\r\n") {
+		t.Errorf("❌ Unexpected content of the file:\n%s", content)
+	}
+	if !strings.Contains(content, "\r\n\r\nHi ramiro,\r\nthe token is abc\r\n") {
+		t.Errorf("❌ The body was not written:\n%s", content)
+	} else {
+		t.Log("✅ Emails appended to the file.")
+	}
+}
+
+// Verify that the headers can not be injected through the address or the subject.
+func TestHeaderInjection(t *testing.T) {
+	s := NewFileSender(filepath.Join(t.TempDir(), "emails.txt"), "no-reply@example.com")
+
+	m := message
+	m.To = "ramiro@example.com\r\nBcc: attacker@example.com"
+
+	if err := s.Send(context.Background(), m); err == nil {
+		t.Errorf("❌ Sent an email with a line break on the address")
+	} else {
+		t.Log("✅ Line breaks rejected on the headers.")
+	}
+}
+
+// Verify that the emails are sent through the SMTP server.
+func TestSMTPSender(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("❌ Could not listen: %v", err)
+	}
+	defer l.Close()
+
+	received := make(chan string, 1)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+
+	for _, from := range []string{"no-reply@example.com", "go-jwt-auth <no-reply@example.com>"} {
+		go fakeSMTPServer(l, received)
+
+		s, err := New(Config{Driver: "smtp", From: from, SMTPHost: host, SMTPPort: port})
+		if err != nil {
+			t.Fatalf("❌ Could not create the sender: %v", err)
+		}
+
+		if err := s.Send(context.Background(), message); err != nil {
+			t.Fatalf("❌ Could not send the email from %s: %v", from, err)
+		}
+
+		// The envelope only has the address, the header keeps the name
+		data := <-received
+		if !strings.Contains(data, "MAIL FROM:<no-reply@example.com>") || !strings.Contains(data, "From: "+from) ||
+			!strings.Contains(data, "RCPT TO:<ramiro@example.com>") || !strings.Contains(data, "Subject: Verify your email") {
+			t.Errorf("❌ Unexpected conversation:\n%s", data)
+		}
+	}
+
+	if _, err := New(Config{Driver: "smtp", From: "no-reply", SMTPHost: host, SMTPPort: port}); err == nil {
+		t.Errorf("❌ Expected the invalid from to be rejected")
+	} else {
+		t.Log("✅ Email sent through SMTP.")
+	}
+}
+
+// Answers a single SMTP conversation and sends what the client wrote
+func fakeSMTPServer(l net.Listener, received chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	data := strings.Builder{}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			break
+		}
+		data.WriteString(line + "\n")
+
+		switch {
+		case strings.HasPrefix(line, "EHLO"):
+			tp.PrintfLine("250 localhost")
+		case line == "DATA":
+			tp.PrintfLine("354 Go ahead")
+			body, _ := tp.ReadDotLines()
+			data.WriteString(strings.Join(body, "\n"))
+			tp.PrintfLine("250 OK")
+		case line == "QUIT":
+			tp.PrintfLine("221 Bye")
+			received <- data.String()
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+	received <- data.String()
+}
@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"time"
+)
+
+// Maximum time to send an email, a server that does not answer must not keep
+// the goroutine forever
+const smtpTimeout = time.Second * 30
+
+// Sender that sends the emails through an SMTP server.
+type smtpSender struct {
+	host string
+	addr string
+	// From header (e.g. "go-jwt-auth <no-reply@localhost>") and the address
+	// alone, which is the sender of the envelope (MAIL FROM)
+	from     string
+	envelope string
+	auth     smtp.Auth
+}
+
+// Returns a Sender that uses the SMTP server of the config.
+//
+// The connection is upgraded with STARTTLS when the server supports it, and
+// the credentials are never sent without TLS (Except to localhost). It fails
+// if the from is not a valid address.
+func NewSMTPSender(c Config) (Sender, error) {
+	from, err := netmail.ParseAddress(c.From)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid mail from %s: %v", c.From, err)
+	}
+
+	s := &smtpSender{
+		host:     c.SMTPHost,
+		addr:     net.JoinHostPort(c.SMTPHost, c.SMTPPort),
+		from:     c.From,
+		envelope: from.Address,
+	}
+	if c.SMTPUsername != "" {
+		s.auth = smtp.PlainAuth("", c.SMTPUsername, c.SMTPPassword, c.SMTPHost)
+	}
+
+	return s, nil
+}
+
+// Same as smtp.SendMail, but it gives up after smtpTimeout or once ctx is done.
+func (s *smtpSender) Send(ctx context.Context, m Message) error {
+	data, err := m.format(s.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	// 1° Connect, every command must finish before the deadline
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	// 2° Upgrade to TLS and authenticate
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if err := c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+
+	// 3° Send the email
+	if err := c.Mail(s.envelope); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
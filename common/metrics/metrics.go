@@ -0,0 +1,132 @@
+// Package metrics exposes the prometheus metrics of the app on /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry with every metric of the app, plus the ones of the go runtime and
+// the process.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	requests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Requests handled, by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time to handle the requests, by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	signUps = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_signups_total",
+		Help: "Sign ups, by result (success or the reason of the failure).",
+	}, []string{"result"})
+
+	signIns = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Logins, by result (success or the reason of the failure).",
+	}, []string{"result"})
+
+	passwordDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "auth_password_duration_seconds",
+		Help: "Time spent by bcrypt, by operation (hash or check).",
+		// bcrypt is slow on purpose, the default buckets are too small
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"operation"})
+
+	tokensIssued = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_tokens_issued_total",
+		Help: "Tokens issued, by type (access or refresh).",
+	}, []string{"type"})
+
+	tokensValidated = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_tokens_validated_total",
+		Help: "Tokens validated, by type (access or refresh) and result (valid or the reason of the rejection).",
+	}, []string{"type", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// GET /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Counts the requests and measures how long they take.
+//
+// The route is the chi pattern (e.g. /api/v1/readbyid) instead of the path,
+// so that the number of series does not grow with every url.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		// The pattern is known once the router has matched the request
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		status := ww.Status()
+		// Handlers that write the body without WriteHeader respond 200
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Exposes the stats of the pool of connections (open, in use, idle, waits...).
+func RegisterDB(name string, db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Counts a sign up, result is "success" or the reason of the failure.
+func ObserveSignUp(result string) {
+	signUps.WithLabelValues(result).Inc()
+}
+
+// Counts a login, result is "success" or the reason of the failure.
+func ObserveSignIn(result string) {
+	signIns.WithLabelValues(result).Inc()
+}
+
+// Measures a bcrypt operation (hash or check) that started at start.
+func ObservePassword(operation string, start time.Time) {
+	passwordDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Counts a token issued, tokenType is access or refresh.
+func ObserveTokenIssued(tokenType string) {
+	tokensIssued.WithLabelValues(tokenType).Inc()
+}
+
+// Counts a token validated, result is "valid" or the reason of the rejection.
+func ObserveTokenValidated(tokenType, result string) {
+	tokensValidated.WithLabelValues(tokenType, result).Inc()
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Verify that the requests are counted by route pattern and not by path.
+func TestMiddleware(t *testing.T) {
+	r := chi.NewMux()
+	r.Use(Middleware)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(requests.WithLabelValues("GET", "/users/{id}", "404")); got != 2 {
+		t.Errorf("❌ Expected 2 requests on /users/{id}, got %v", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("❌ Expected 1 unmatched request, got %v", got)
+	} else {
+		t.Log("✅ Requests counted by route pattern.")
+	}
+}
@@ -0,0 +1,200 @@
+// Package problem defines the errors sent to the clients, as RFC 7807 problem
+// details (application/problem+json).
+//
+// Every error has a stable code that the clients can rely on, the message of
+// the error may change. The internal errors (Database, bcrypt...) are mapped
+// to their status, and their details are only written on the logs.
+package problem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Content type of the errors
+const ContentType = "application/problem+json"
+
+// Codes of the errors, they are part of the API so they must not be changed.
+const (
+	CodeInvalidBody      = "invalid_body"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeValidationFailed = "validation_failed"
+
+	CodeUnauthorized        = "unauthorized"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeTokenRevoked        = "token_revoked"
+	CodeInvalidRefreshToken = "invalid_refresh_token"
+	CodeRefreshTokenExpired = "refresh_token_expired"
+	CodeRefreshTokenReused  = "refresh_token_reused"
+
+	CodeInvalidResetToken = "invalid_reset_token"
+	CodeResetTokenExpired = "reset_token_expired"
+
+	CodeInvalidVerificationToken = "invalid_verification_token"
+	CodeVerificationTokenExpired = "verification_token_expired"
+
+	CodeForbidden        = "forbidden"
+	CodeWrongPassword    = "wrong_password"
+	CodeEmailNotVerified = "email_not_verified"
+
+	CodeNotFound     = "not_found"
+	CodeUserNotFound = "user_not_found"
+
+	CodeConflict          = "conflict"
+	CodeUserAlreadyExists = "user_already_exists"
+	CodeVersionMismatch   = "version_mismatch"
+
+	CodePreconditionFailed   = "precondition_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeTooManyRequests      = "too_many_requests"
+
+	CodeInternal    = "internal_error"
+	CodeUnavailable = "service_unavailable"
+)
+
+// Error is an error that can be sent to the client.
+//
+// Err is the cause, it's logged but never sent. Fields are the errors of each
+// field of the request, e.g. {"email": "Email must be valid"}.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields map[string]string
+	Err    error
+}
+
+// Returns an error with the status, the code and the message sent to the client.
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+// Same as New, err is the cause of the error.
+func Wrap(err error, status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Detail, e.Err)
+	}
+
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// An error that is mapped to a status, a code and the message sent to the
+// client (See Register)
+type mapping struct {
+	target error
+	status int
+	code   string
+	detail string
+}
+
+var (
+	mu       sync.RWMutex
+	mappings = []mapping{
+		{sql.ErrNoRows, http.StatusNotFound, CodeNotFound, "Resource not found"},
+		{bcrypt.ErrMismatchedHashAndPassword, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid email or password"},
+	}
+)
+
+// Maps the errors that are errors.Is target to the status, the code and the
+// detail sent to the client. The message of target is only logged, so the
+// detail must be written for the client.
+//
+// The packages register their own errors, e.g. the user not found of the repository.
+func Register(target error, status int, code, detail string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	mappings = append(mappings, mapping{target, status, code, detail})
+}
+
+// Returns the error that is sent to the client for err.
+//
+// Any error that is not an *Error nor mapped is an internal error, its
+// message is not sent.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, m := range mappings {
+		if errors.Is(err, m.target) {
+			return &Error{Status: m.status, Code: m.code, Detail: m.detail, Err: err}
+		}
+	}
+
+	// 23505 is the code of unique_violation
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return Wrap(err, http.StatusConflict, CodeConflict, "The resource already exists")
+	}
+
+	return Wrap(err, http.StatusInternalServerError, CodeInternal, "An unexpected error occurred")
+}
+
+// Problem is the body of the errors (RFC 7807), code and request_id are extensions.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail"`
+	Instance  string            `json:"instance"`
+	Code      string            `json:"code"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// Logs err and sends it to the client as a problem.
+//
+// The internal errors are logged at error level, the errors of the client at info.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+
+	log := logger.FromContext(r.Context())
+	if e.Status >= http.StatusInternalServerError {
+		log.Errorw(e.Detail, "status", e.Status, "code", e.Code, "error", err)
+	} else {
+		log.Infow(e.Detail, "status", e.Status, "code", e.Code, "error", err)
+	}
+
+	// There is no page that documents the errors, so the type is about:blank
+	// and the title the one of the status
+	body, _ := json.Marshal(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Status),
+		Status:    e.Status,
+		Detail:    e.Detail,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		Errors:    e.Fields,
+		RequestID: logger.RequestID(r.Context()),
+	})
+
+	// Only the missing or invalid JWT are a bearer challenge, not the wrong
+	// credentials nor the refresh tokens
+	if e.Code == CodeUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(e.Status)
+	w.Write(body)
+}
@@ -0,0 +1,114 @@
+package problem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Verify that the internal errors are mapped to their status and code.
+func TestFrom(t *testing.T) {
+	errRegistered := errors.New("Registered")
+	Register(errRegistered, http.StatusConflict, CodeConflict, "Already registered")
+
+	tests := []struct {
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{New(http.StatusForbidden, CodeForbidden, "Forbidden"), http.StatusForbidden, CodeForbidden, "Forbidden"},
+		{fmt.Errorf("Wrapped: %w", New(http.StatusBadRequest, CodeInvalidBody, "Bad body")), http.StatusBadRequest, CodeInvalidBody, "Bad body"},
+		{fmt.Errorf("Query failed: %w", sql.ErrNoRows), http.StatusNotFound, CodeNotFound, "Resource not found"},
+		{bcrypt.ErrMismatchedHashAndPassword, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid email or password"},
+		{&pq.Error{Code: "23505"}, http.StatusConflict, CodeConflict, "The resource already exists"},
+		{fmt.Errorf("Create: %w", errRegistered), http.StatusConflict, CodeConflict, "Already registered"},
+		{errors.New("dial tcp: connection refused"), http.StatusInternalServerError, CodeInternal, "An unexpected error occurred"},
+	}
+
+	for _, tt := range tests {
+		e := From(tt.err)
+		if e.Status != tt.status || e.Code != tt.code || e.Detail != tt.detail {
+			t.Errorf("❌ %v: expected %d %s %q, got %d %s %q", tt.err, tt.status, tt.code, tt.detail, e.Status, e.Code, e.Detail)
+		}
+	}
+
+	t.Log("✅ Errors mapped.")
+}
+
+// Verify that the problem is valid json and the internal details are not sent.
+func TestWrite(t *testing.T) {
+	logger.InitZapLogger()
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/readall", nil)
+	w := httptest.NewRecorder()
+	Write(w, r, errors.New(`pq: password authentication failed for user "postgres"`))
+
+	p := Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("❌ Invalid json %s: %v", w.Body.String(), err)
+	}
+
+	switch {
+	case w.Header().Get("Content-Type") != ContentType:
+		t.Errorf("❌ Unexpected Content-Type %s", w.Header().Get("Content-Type"))
+	case w.Code != http.StatusInternalServerError || p.Status != w.Code || p.Code != CodeInternal:
+		t.Errorf("❌ Unexpected problem %d %+v", w.Code, p)
+	case p.Detail != "An unexpected error occurred" || p.Instance != "/api/v1/readall":
+		t.Errorf("❌ The internal error was sent: %+v", p)
+	default:
+		t.Log("✅ Problem written.")
+	}
+}
+
+// Verify that the messages with quotes are escaped.
+func TestWriteQuotes(t *testing.T) {
+	logger.InitZapLogger()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/register", nil)
+	w := httptest.NewRecorder()
+	Write(w, r, New(http.StatusBadRequest, CodeValidationFailed, `The "username" is required`))
+
+	p := Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Errorf("❌ Invalid json %s: %v", w.Body.String(), err)
+	} else if p.Detail != `The "username" is required` {
+		t.Errorf("❌ Unexpected detail %s", p.Detail)
+	} else {
+		t.Log("✅ Quotes escaped.")
+	}
+}
+
+// Verify that only the 401 of the JWT ask for a bearer token.
+func TestWriteAuthenticate(t *testing.T) {
+	logger.InitZapLogger()
+
+	tests := []struct {
+		err       error
+		challenge string
+	}{
+		{New(http.StatusUnauthorized, CodeUnauthorized, "Missing token"), "Bearer"},
+		{bcrypt.ErrMismatchedHashAndPassword, ""},
+		{New(http.StatusUnauthorized, CodeInvalidRefreshToken, "Invalid refresh token"), ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/login", nil)
+		w := httptest.NewRecorder()
+		Write(w, r, tt.err)
+
+		if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+			t.Errorf("❌ %v: expected WWW-Authenticate %q, got %q", tt.err, tt.challenge, got)
+		}
+	}
+
+	t.Log("✅ Bearer challenge only on the 401 of the JWT.")
+}
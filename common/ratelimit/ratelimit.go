@@ -0,0 +1,75 @@
+// Package ratelimit limits how many times something can be done on a window
+// of time, e.g. how many password reset emails are sent to an address.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to limit events per key on every window (Fixed window).
+//
+// The counters are kept in memory, so every replica of the app has its own.
+type Limiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	windows   map[string]*window
+	nextSweep time.Time
+}
+
+// Events of a key on the current window
+type window struct {
+	count   int
+	resetAt time.Time
+}
+
+// Returns a Limiter that allows limit events per key on every period.
+func New(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  period,
+		now:     time.Now,
+		windows: map[string]*window{},
+	}
+}
+
+// Records an event of the key, it returns false if the key reached the limit.
+// In that case retryAfter is the time left until the window ends.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	w, found := l.windows[key]
+	if !found || !now.Before(w.resetAt) {
+		w = &window{resetAt: now.Add(l.window)}
+		l.windows[key] = w
+	}
+
+	if w.count >= l.limit {
+		return false, w.resetAt.Sub(now)
+	}
+
+	w.count++
+	return true, 0
+}
+
+// Removes the windows that ended, so the keys that are not used anymore don't
+// use memory forever. It runs at most once per window.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+
+	for key, w := range l.windows {
+		if !now.Before(w.resetAt) {
+			delete(l.windows, key)
+		}
+	}
+	l.nextSweep = now.Add(l.window)
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// Verify that a key is limited until its window ends, and that the keys are independent.
+func TestAllow(t *testing.T) {
+	now := time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	l := New(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("❌ Event %d should be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := l.Allow("a")
+	if ok || retryAfter != time.Minute {
+		t.Errorf("❌ Expected the third event to be limited for a minute, got %v %v", ok, retryAfter)
+	}
+
+	if ok, _ := l.Allow("b"); !ok {
+		t.Errorf("❌ Another key should not be limited")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Errorf("❌ The key should be allowed once the window ends")
+	} else {
+		t.Log("✅ Events limited per key and window.")
+	}
+}
+
+// Verify that the windows that ended are removed.
+func TestSweep(t *testing.T) {
+	now := time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+	l := New(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+
+	now = now.Add(2 * time.Minute)
+	l.Allow("c")
+
+	if len(l.windows) != 1 {
+		t.Errorf("❌ Expected only the window of c, got %d windows", len(l.windows))
+	} else {
+		t.Log("✅ Ended windows removed.")
+	}
+}
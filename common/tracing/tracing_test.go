@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Verify that the request span continues the trace of the traceparent header,
+// is named after the route and is the parent of the spans of the handler.
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := chi.NewMux()
+	r.Use(Middleware)
+	r.Post("/login", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "PasswordCheck")
+		span.End()
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("❌ Expected 2 spans, got %d", len(spans))
+	}
+
+	child, server := spans[0], spans[1]
+
+	if server.Name() != "POST /login" {
+		t.Errorf("❌ Expected the span to be named after the route, got %s", server.Name())
+	}
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("❌ The trace of the traceparent header was not continued")
+	}
+	if child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("❌ The span of the handler is not a child of the request span")
+	} else {
+		t.Log("✅ Request traced.")
+	}
+}
@@ -0,0 +1,483 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	netmail "net/mail"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/mail"
+	"github.com/RamiroCuenca/go-jwt-auth/common/tracing"
+	"github.com/RamiroCuenca/go-jwt-auth/database/connection"
+	toml "github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config of the whole application.
+//
+// It's loaded at startup by Load, from the defaults, the config file and the
+// environment variables (In that order, the last one wins).
+type Config struct {
+	Server   Server   `yaml:"server" toml:"server"`
+	Auth     Auth     `yaml:"auth" toml:"auth"`
+	Database Database `yaml:"database" toml:"database"`
+	Log      Log      `yaml:"log" toml:"log"`
+	Tracing  Tracing  `yaml:"tracing" toml:"tracing"`
+	Mail     Mail     `yaml:"mail" toml:"mail"`
+}
+
+// Config of the http server.
+type Server struct {
+	// Address where the server listens (e.g. ":8000").
+	Address string `yaml:"address" toml:"address"`
+	// Url where the clients reach the app, used on the links of the emails.
+	PublicURL    string   `yaml:"public_url" toml:"public_url"`
+	ReadTimeout  Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout Duration `yaml:"write_timeout" toml:"write_timeout"`
+	// Maximum time to wait for the in-flight requests when the app stops.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// Maximum time of each check of /readyz.
+	HealthCheckTimeout Duration `yaml:"health_check_timeout" toml:"health_check_timeout"`
+	// Addresses or ranges of the reverse proxies in front of the app, the
+	// client ip is read from their X-Forwarded-For.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// Config of the signing keys and the tokens.
+type Auth struct {
+	// Directory with the signing keys (See the README).
+	KeysDir string `yaml:"keys_dir" toml:"keys_dir"`
+	// Kid of the key used to sign, by default the newest key.
+	ActiveKid string `yaml:"active_kid" toml:"active_kid"`
+	// Algorithm of the keys used to sign (RS256, ES256, EdDSA or HS256).
+	Algorithm string `yaml:"algorithm" toml:"algorithm"`
+	// How often the keys are reloaded from KeysDir.
+	KeysReloadInterval Duration `yaml:"keys_reload_interval" toml:"keys_reload_interval"`
+
+	Issuer               string   `yaml:"issuer" toml:"issuer"`
+	Audience             string   `yaml:"audience" toml:"audience"`
+	AccessTokenDuration  Duration `yaml:"access_token_duration" toml:"access_token_duration"`
+	RefreshTokenDuration Duration `yaml:"refresh_token_duration" toml:"refresh_token_duration"`
+	ResetTokenDuration   Duration `yaml:"reset_token_duration" toml:"reset_token_duration"`
+	Leeway               Duration `yaml:"leeway" toml:"leeway"`
+
+	// What the users can do until they verify their email: off, restrict or
+	// block. It's off by default, the restrictions are opt-in.
+	EmailVerification         string   `yaml:"email_verification" toml:"email_verification"`
+	VerificationTokenDuration Duration `yaml:"verification_token_duration" toml:"verification_token_duration"`
+}
+
+// Config of the storage.
+type Database struct {
+	// postgres, sqlite or memory.
+	Driver string `yaml:"driver" toml:"driver"`
+	// Applies the pending migrations when the app starts (Only postgres).
+	MigrateOnStart bool `yaml:"migrate_on_start" toml:"migrate_on_start"`
+	// File of the SQLite database.
+	SQLitePath string `yaml:"sqlite_path" toml:"sqlite_path"`
+
+	// If DSN is set it's used as is and the connection fields are ignored.
+	DSN         string `yaml:"dsn" toml:"dsn"`
+	Host        string `yaml:"host" toml:"host"`
+	Port        string `yaml:"port" toml:"port"`
+	User        string `yaml:"user" toml:"user"`
+	Password    string `yaml:"password" toml:"password"`
+	Name        string `yaml:"name" toml:"name"`
+	SSLMode     string `yaml:"sslmode" toml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert" toml:"sslrootcert"`
+
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+}
+
+// Config of the logger.
+type Log struct {
+	// json or console. If it's empty the console is used on development and
+	// json otherwise.
+	Format string `yaml:"format" toml:"format"`
+	// Development adds the stack traces to the warnings.
+	Development bool `yaml:"development" toml:"development"`
+	// debug, info, warn or error. It can be changed while the app runs with
+	// /api/v1/admin/loglevel.
+	Level string `yaml:"level" toml:"level"`
+
+	// After sampling_initial equal logs in a second, only one of every
+	// sampling_thereafter is written. 0 disables the sampling.
+	SamplingInitial    int `yaml:"sampling_initial" toml:"sampling_initial"`
+	SamplingThereafter int `yaml:"sampling_thereafter" toml:"sampling_thereafter"`
+
+	// File where the logs are written, stderr if it's empty. It's rotated
+	// once it reaches max_size_mb.
+	File       string `yaml:"file" toml:"file"`
+	MaxSizeMB  int    `yaml:"max_size_mb" toml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups" toml:"max_backups"`
+	MaxAgeDays int    `yaml:"max_age_days" toml:"max_age_days"`
+	Compress   bool   `yaml:"compress" toml:"compress"`
+}
+
+// Config of the OpenTelemetry tracing.
+type Tracing struct {
+	// none, stdout or otlp.
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	// host:port of the OTLP/HTTP collector.
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+	// Sends the spans to the collector without TLS.
+	Insecure    bool    `yaml:"insecure" toml:"insecure"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// Config of the emails.
+type Mail struct {
+	// log, file or smtp.
+	Driver string `yaml:"driver" toml:"driver"`
+	// Address the emails are sent from.
+	From string `yaml:"from" toml:"from"`
+	// File where the emails are appended by the file driver.
+	File string `yaml:"file" toml:"file"`
+
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host"`
+	SMTPPort     string `yaml:"smtp_port" toml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password"`
+}
+
+// Duration that can be written as "2h", "30s"... on the config file.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// Returns the config used for local development.
+func Default() Config {
+	o := auth.DefaultOptions()
+	db := connection.DefaultConfig()
+	l := logger.DefaultConfig()
+
+	return Config{
+		Server: Server{
+			Address:            ":8000",
+			PublicURL:          "http://localhost:8000",
+			ReadTimeout:        Duration(time.Second * 10),
+			WriteTimeout:       Duration(time.Second * 10),
+			ShutdownTimeout:    Duration(time.Second * 15),
+			HealthCheckTimeout: Duration(time.Second * 2),
+		},
+		Auth: Auth{
+			KeysDir:              "certificates",
+			KeysReloadInterval:   Duration(time.Minute * 5),
+			Issuer:               o.Issuer,
+			Audience:             o.Audience,
+			AccessTokenDuration:  Duration(o.AccessTokenDuration),
+			RefreshTokenDuration: Duration(o.RefreshTokenDuration),
+			ResetTokenDuration:   Duration(o.ResetTokenDuration),
+			Leeway:               Duration(o.Leeway),
+			// The new users can only access their account until they verify
+			// the email
+			EmailVerification:         "off",
+			VerificationTokenDuration: Duration(o.VerificationTokenDuration),
+		},
+		Database: Database{
+			Driver:          "postgres",
+			MigrateOnStart:  true,
+			SQLitePath:      "go-auth.db",
+			Host:            db.Host,
+			Port:            db.Port,
+			User:            db.User,
+			Password:        db.Password,
+			Name:            db.DBName,
+			SSLMode:         db.SSLMode,
+			MaxOpenConns:    db.MaxOpenConns,
+			MaxIdleConns:    db.MaxIdleConns,
+			ConnMaxLifetime: Duration(db.ConnMaxLifetime),
+			ConnMaxIdleTime: Duration(db.ConnMaxIdleTime),
+		},
+		// Console logs at debug level for local development, the sampling and
+		// the rotation of production.
+		Log: Log{
+			Development:        true,
+			Level:              "debug",
+			SamplingInitial:    l.SamplingInitial,
+			SamplingThereafter: l.SamplingThereafter,
+			MaxSizeMB:          l.MaxSizeMB,
+			MaxBackups:         l.MaxBackups,
+			MaxAgeDays:         l.MaxAgeDays,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			ServiceName: "go-jwt-auth",
+			SampleRatio: 1,
+		},
+		// The emails are written on the logs, so no server is needed
+		Mail: Mail{
+			Driver:   "log",
+			From:     "go-jwt-auth <no-reply@localhost>",
+			File:     "emails.txt",
+			SMTPPort: "587",
+		},
+	}
+}
+
+// Loads the config.
+//
+// 1° The defaults (See Default)
+// 2° The file (If path is not empty), .yaml, .yml or .toml
+// 3° The environment variables (See the README)
+//
+// The result is validated, so the app can trust it.
+func Load(path string, getenv func(string) string) (Config, error) {
+	c := Default()
+
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := c.applyEnv(getenv); err != nil {
+		return Config{}, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// Reads the file over the current values, unknown fields are rejected so
+// that typos don't go unnoticed.
+func (c *Config) readFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Could not read the config file: %v", err)
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+		// An empty file is a valid config
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("Unknown format of the config file %s, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Checks every field and returns all the problems at once.
+func (c Config) Validate() error {
+	problems := []string{}
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Address != "", "server.address is required")
+	check(strings.HasPrefix(c.Server.PublicURL, "http://") || strings.HasPrefix(c.Server.PublicURL, "https://"), "server.public_url must be an http or https url")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout must be greater than 0")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout must be greater than 0")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be greater than 0")
+	check(c.Server.HealthCheckTimeout > 0, "server.health_check_timeout must be greater than 0")
+	if _, err := handler.ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		check(false, "server.trusted_proxies: %v", err)
+	}
+
+	check(c.Auth.KeysDir != "", "auth.keys_dir is required")
+	check(c.Auth.KeysReloadInterval > 0, "auth.keys_reload_interval must be greater than 0")
+	check(c.Auth.Issuer != "", "auth.issuer is required")
+	check(c.Auth.Audience != "", "auth.audience is required")
+	check(c.Auth.AccessTokenDuration > 0, "auth.access_token_duration must be greater than 0")
+	check(c.Auth.RefreshTokenDuration > 0, "auth.refresh_token_duration must be greater than 0")
+	check(c.Auth.ResetTokenDuration > 0, "auth.reset_token_duration must be greater than 0")
+	check(c.Auth.VerificationTokenDuration > 0, "auth.verification_token_duration must be greater than 0")
+	switch c.Auth.EmailVerification {
+	case "off", "restrict", "block":
+	default:
+		check(false, "auth.email_verification %s is not supported, use off, restrict or block", c.Auth.EmailVerification)
+	}
+	check(c.Auth.Leeway >= 0, "auth.leeway can not be negative")
+	switch c.Auth.Algorithm {
+	case "", "RS256", "ES256", "EdDSA", "HS256":
+	default:
+		check(false, "auth.algorithm %s is not supported, use RS256, ES256, EdDSA or HS256", c.Auth.Algorithm)
+	}
+
+	switch c.Database.Driver {
+	case "postgres":
+		if c.Database.DSN == "" {
+			check(c.Database.Host != "", "database.host is required")
+			check(c.Database.Port != "", "database.port is required")
+			check(c.Database.User != "", "database.user is required")
+			check(c.Database.Name != "", "database.name is required")
+		}
+		switch c.Database.SSLMode {
+		case "disable", "require", "verify-ca", "verify-full":
+		default:
+			check(false, "database.sslmode %s is not supported, use disable, require, verify-ca or verify-full", c.Database.SSLMode)
+		}
+		check(c.Database.MaxOpenConns >= 0, "database.max_open_conns can not be negative")
+		check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns can not be negative")
+		check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime can not be negative")
+		check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time can not be negative")
+	case "sqlite":
+		check(c.Database.SQLitePath != "", "database.sqlite_path is required")
+	case "memory":
+	default:
+		check(false, "database.driver %s is not supported, use postgres, sqlite or memory", c.Database.Driver)
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level %s is not supported, use debug, info, warn or error", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "", "json", "console":
+	default:
+		check(false, "log.format %s is not supported, use json or console", c.Log.Format)
+	}
+	check(c.Log.SamplingInitial >= 0 && c.Log.SamplingThereafter >= 0, "log.sampling_initial and log.sampling_thereafter can not be negative")
+	if c.Log.File != "" {
+		check(c.Log.MaxSizeMB > 0, "log.max_size_mb must be greater than 0")
+		check(c.Log.MaxBackups >= 0 && c.Log.MaxAgeDays >= 0, "log.max_backups and log.max_age_days can not be negative")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		check(c.Tracing.Endpoint != "", "tracing.endpoint is required")
+	default:
+		check(false, "tracing.exporter %s is not supported, use none, stdout or otlp", c.Tracing.Exporter)
+	}
+	check(c.Tracing.ServiceName != "", "tracing.service_name is required")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+
+	switch c.Mail.Driver {
+	case "log":
+	case "file":
+		check(c.Mail.File != "", "mail.file is required")
+	case "smtp":
+		check(c.Mail.SMTPHost != "", "mail.smtp_host is required")
+		check(c.Mail.SMTPPort != "", "mail.smtp_port is required")
+	default:
+		check(false, "mail.driver %s is not supported, use log, file or smtp", c.Mail.Driver)
+	}
+	if _, err := netmail.ParseAddress(c.Mail.From); err != nil {
+		check(false, "mail.from %s is not a valid address, e.g. name <user@example.com>", c.Mail.From)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Invalid config:\n  - " + strings.Join(problems, "\n  - "))
+	}
+
+	return nil
+}
+
+// Proxies whose X-Forwarded-For is trusted, they must be valid (See Validate).
+func (s Server) Proxies() handler.TrustedProxies {
+	proxies, _ := handler.ParseTrustedProxies(s.TrustedProxies)
+	return proxies
+}
+
+// Options of the tokens (See auth.Configure).
+func (a Auth) Options() auth.Options {
+	return auth.Options{
+		Issuer:               a.Issuer,
+		Audience:             a.Audience,
+		AccessTokenDuration:  time.Duration(a.AccessTokenDuration),
+		RefreshTokenDuration: time.Duration(a.RefreshTokenDuration),
+		ResetTokenDuration:   time.Duration(a.ResetTokenDuration),
+		Leeway:               time.Duration(a.Leeway),
+
+		VerificationTokenDuration: time.Duration(a.VerificationTokenDuration),
+	}
+}
+
+// Config of the postgres pool (See connection.NewPostgresClient).
+func (d Database) Postgres() connection.Config {
+	return connection.Config{
+		DSN:             d.DSN,
+		Host:            d.Host,
+		Port:            d.Port,
+		User:            d.User,
+		Password:        d.Password,
+		DBName:          d.Name,
+		SSLMode:         d.SSLMode,
+		SSLRootCert:     d.SSLRootCert,
+		MaxOpenConns:    d.MaxOpenConns,
+		MaxIdleConns:    d.MaxIdleConns,
+		ConnMaxLifetime: time.Duration(d.ConnMaxLifetime),
+		ConnMaxIdleTime: time.Duration(d.ConnMaxIdleTime),
+	}
+}
+
+// Config of the logger (See logger.Init).
+func (l Log) Logger() logger.Config {
+	return logger.Config{
+		Format:             l.Format,
+		Development:        l.Development,
+		Level:              l.Level,
+		SamplingInitial:    l.SamplingInitial,
+		SamplingThereafter: l.SamplingThereafter,
+		File:               l.File,
+		MaxSizeMB:          l.MaxSizeMB,
+		MaxBackups:         l.MaxBackups,
+		MaxAgeDays:         l.MaxAgeDays,
+		Compress:           l.Compress,
+	}
+}
+
+// Config of the tracing (See tracing.Init).
+func (t Tracing) Tracing() tracing.Config {
+	return tracing.Config{
+		Exporter:    t.Exporter,
+		Endpoint:    t.Endpoint,
+		Insecure:    t.Insecure,
+		ServiceName: t.ServiceName,
+		SampleRatio: t.SampleRatio,
+	}
+}
+
+// Config of the sender of the emails (See mail.New).
+func (m Mail) Mail() mail.Config {
+	return mail.Config{
+		Driver:       m.Driver,
+		From:         m.From,
+		File:         m.File,
+		SMTPHost:     m.SMTPHost,
+		SMTPPort:     m.SMTPPort,
+		SMTPUsername: m.SMTPUsername,
+		SMTPPassword: m.SMTPPassword,
+	}
+}
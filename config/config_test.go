@@ -0,0 +1,150 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a getenv that reads from the map instead of the environment
+func testEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+// Writes the config file on a temporary directory and returns its path
+func writeTestConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("❌ Could not write the config file: %v", err)
+	}
+
+	return path
+}
+
+// Verify that the defaults are valid, so the app can run without a config file.
+func TestLoadDefaults(t *testing.T) {
+	c, err := Load("", testEnv(nil))
+	if err != nil {
+		t.Fatalf("❌ The defaults are not valid: %v", err)
+	}
+
+	if c.Server.Address != ":8000" || c.Database.Driver != "postgres" || c.Auth.EmailVerification != "off" {
+		t.Errorf("❌ Unexpected defaults %+v", c)
+	} else {
+		t.Log("✅ Defaults loaded.")
+	}
+}
+
+// Verify that the YAML and TOML files are read and the environment overrides them.
+func TestLoadFileAndEnv(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+server:
+  address: ":9000"
+auth:
+  access_token_duration: 15m
+database:
+  driver: memory
+`,
+		"config.toml": `
+[server]
+address = ":9000"
+
+[auth]
+access_token_duration = "15m"
+
+[database]
+driver = "memory"
+`,
+	}
+
+	for name, content := range files {
+		path := writeTestConfig(t, name, content)
+
+		c, err := Load(path, testEnv(map[string]string{"SERVER_ADDRESS": ":9001"}))
+		if err != nil {
+			t.Fatalf("❌ Could not load %s: %v", name, err)
+		}
+
+		if c.Server.Address != ":9001" {
+			t.Errorf("❌ %s: the environment should override the file, got %s", name, c.Server.Address)
+		}
+		if time.Duration(c.Auth.AccessTokenDuration) != time.Minute*15 || c.Database.Driver != "memory" {
+			t.Errorf("❌ %s: the file was not applied %+v", name, c)
+		} else {
+			t.Logf("✅ %s loaded.", name)
+		}
+	}
+}
+
+// Verify that every invalid field is reported at once, and that typos on the file are rejected.
+func TestLoadInvalid(t *testing.T) {
+	_, err := Load("", testEnv(map[string]string{
+		"DB_DRIVER": "mysql",
+		"LOG_LEVEL": "verbose",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "database.driver") || !strings.Contains(err.Error(), "log.level") {
+		t.Errorf("❌ Expected both problems to be reported, got %v", err)
+	}
+
+	_, err = Load("", testEnv(map[string]string{"JWT_LEEWAY": "soon"}))
+	if err == nil || !strings.Contains(err.Error(), "JWT_LEEWAY") {
+		t.Errorf("❌ Expected the invalid duration to be reported, got %v", err)
+	}
+
+	path := writeTestConfig(t, "config.yaml", "server:\n  adress: \":9000\"\n")
+	if _, err := Load(path, testEnv(nil)); err == nil {
+		t.Errorf("❌ Expected the unknown field to be rejected")
+	} else {
+		t.Log("✅ Invalid configs rejected.")
+	}
+}
+
+// Verify the options of the emails and of their verification.
+func TestLoadMail(t *testing.T) {
+	c, err := Load("", testEnv(map[string]string{
+		"MAIL_DRIVER":        "smtp",
+		"SMTP_HOST":          "smtp.example.com",
+		"EMAIL_VERIFICATION": "block",
+	}))
+	if err != nil {
+		t.Fatalf("❌ Could not load the config: %v", err)
+	}
+	if m := c.Mail.Mail(); m.Driver != "smtp" || m.SMTPHost != "smtp.example.com" || m.SMTPPort != "587" || c.Auth.EmailVerification != "block" {
+		t.Errorf("❌ Unexpected config %+v %s", m, c.Auth.EmailVerification)
+	}
+
+	_, err = Load("", testEnv(map[string]string{
+		"MAIL_DRIVER":        "smtp",
+		"MAIL_FROM":          "no-reply",
+		"EMAIL_VERIFICATION": "sometimes",
+	}))
+	if err == nil || !strings.Contains(err.Error(), "mail.smtp_host") || !strings.Contains(err.Error(), "auth.email_verification") ||
+		!strings.Contains(err.Error(), "mail.from") {
+		t.Errorf("❌ Expected the three problems to be reported, got %v", err)
+	} else {
+		t.Log("✅ Mail config loaded and checked.")
+	}
+}
+
+// Verify that the trusted proxies are read from the environment and checked.
+func TestLoadTrustedProxies(t *testing.T) {
+	c, err := Load("", testEnv(map[string]string{"SERVER_TRUSTED_PROXIES": "10.0.0.0/8, 192.168.1.1"}))
+	if err != nil {
+		t.Fatalf("❌ Could not load the config: %v", err)
+	}
+	if proxies := c.Server.Proxies(); len(proxies) != 2 {
+		t.Errorf("❌ Expected 2 proxies, got %v", proxies)
+	}
+
+	_, err = Load("", testEnv(map[string]string{"SERVER_TRUSTED_PROXIES": "10.0.0.0/8,localhost"}))
+	if err == nil || !strings.Contains(err.Error(), "server.trusted_proxies") {
+		t.Errorf("❌ Expected the invalid proxy to be reported, got %v", err)
+	} else {
+		t.Log("✅ Trusted proxies loaded and checked.")
+	}
+}
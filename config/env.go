@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Overrides the values with the environment variables that are set.
+//
+// getenv is usually os.Getenv, it's received so that the tests don't need to
+// touch the environment of the process.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	texts := map[string]*string{
+		"SERVER_ADDRESS":    &c.Server.Address,
+		"SERVER_PUBLIC_URL": &c.Server.PublicURL,
+
+		"JWT_KEYS_DIR":   &c.Auth.KeysDir,
+		"JWT_ACTIVE_KID": &c.Auth.ActiveKid,
+		"JWT_ALGORITHM":  &c.Auth.Algorithm,
+		"JWT_ISSUER":     &c.Auth.Issuer,
+		"JWT_AUDIENCE":   &c.Auth.Audience,
+
+		"EMAIL_VERIFICATION": &c.Auth.EmailVerification,
+
+		"DB_DRIVER":      &c.Database.Driver,
+		"SQLITE_PATH":    &c.Database.SQLitePath,
+		"DATABASE_URL":   &c.Database.DSN,
+		"DB_HOST":        &c.Database.Host,
+		"DB_PORT":        &c.Database.Port,
+		"DB_USER":        &c.Database.User,
+		"DB_PASSWORD":    &c.Database.Password,
+		"DB_NAME":        &c.Database.Name,
+		"DB_SSLMODE":     &c.Database.SSLMode,
+		"DB_SSLROOTCERT": &c.Database.SSLRootCert,
+
+		"LOG_FORMAT": &c.Log.Format,
+		"LOG_LEVEL":  &c.Log.Level,
+		"LOG_FILE":   &c.Log.File,
+
+		"TRACING_EXPORTER":     &c.Tracing.Exporter,
+		"TRACING_ENDPOINT":     &c.Tracing.Endpoint,
+		"TRACING_SERVICE_NAME": &c.Tracing.ServiceName,
+
+		"MAIL_DRIVER":   &c.Mail.Driver,
+		"MAIL_FROM":     &c.Mail.From,
+		"MAIL_FILE":     &c.Mail.File,
+		"SMTP_HOST":     &c.Mail.SMTPHost,
+		"SMTP_PORT":     &c.Mail.SMTPPort,
+		"SMTP_USERNAME": &c.Mail.SMTPUsername,
+		"SMTP_PASSWORD": &c.Mail.SMTPPassword,
+	}
+	for env, s := range texts {
+		if v := getenv(env); v != "" {
+			*s = v
+		}
+	}
+
+	ints := map[string]*int{
+		"DB_MAX_OPEN_CONNS": &c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &c.Database.MaxIdleConns,
+
+		"LOG_SAMPLING_INITIAL":    &c.Log.SamplingInitial,
+		"LOG_SAMPLING_THEREAFTER": &c.Log.SamplingThereafter,
+		"LOG_MAX_SIZE_MB":         &c.Log.MaxSizeMB,
+		"LOG_MAX_BACKUPS":         &c.Log.MaxBackups,
+		"LOG_MAX_AGE_DAYS":        &c.Log.MaxAgeDays,
+	}
+	for env, i := range ints {
+		v := getenv(env)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid %s, it must be a number", env)
+		}
+		*i = parsed
+	}
+
+	bools := map[string]*bool{
+		"DB_MIGRATE_ON_START": &c.Database.MigrateOnStart,
+		"LOG_DEVELOPMENT":     &c.Log.Development,
+		"LOG_COMPRESS":        &c.Log.Compress,
+		"TRACING_INSECURE":    &c.Tracing.Insecure,
+	}
+	for env, b := range bools {
+		v := getenv(env)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("Invalid %s, it must be true or false", env)
+		}
+		*b = parsed
+	}
+
+	// Comma separated, e.g. 10.0.0.0/8,192.168.1.1
+	if v := getenv("SERVER_TRUSTED_PROXIES"); v != "" {
+		c.Server.TrustedProxies = strings.Split(v, ",")
+	}
+
+	if v := getenv("TRACING_SAMPLE_RATIO"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid TRACING_SAMPLE_RATIO, it must be a number between 0 and 1")
+		}
+		c.Tracing.SampleRatio = parsed
+	}
+
+	durations := map[string]*Duration{
+		"SERVER_READ_TIMEOUT":         &c.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":        &c.Server.WriteTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT":     &c.Server.ShutdownTimeout,
+		"SERVER_HEALTH_CHECK_TIMEOUT": &c.Server.HealthCheckTimeout,
+		"JWT_KEYS_RELOAD_INTERVAL":    &c.Auth.KeysReloadInterval,
+		"JWT_ACCESS_TOKEN_DURATION":   &c.Auth.AccessTokenDuration,
+		"JWT_REFRESH_TOKEN_DURATION":  &c.Auth.RefreshTokenDuration,
+		"RESET_TOKEN_DURATION":        &c.Auth.ResetTokenDuration,
+		"VERIFICATION_TOKEN_DURATION": &c.Auth.VerificationTokenDuration,
+		"JWT_LEEWAY":                  &c.Auth.Leeway,
+		"DB_CONN_MAX_LIFETIME":        &c.Database.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME":       &c.Database.ConnMaxIdleTime,
+	}
+	for env, d := range durations {
+		v := getenv(env)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("Invalid %s, it must be a duration like 30s or 2h", env)
+		}
+		*d = Duration(parsed)
+	}
+
+	return nil
+}
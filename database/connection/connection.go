@@ -2,8 +2,10 @@ package connection
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"sync"
+	"net/url"
+	"time"
 
 	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
 	_ "github.com/lib/pq" // Dont forget to import it, it provides the drivers for postgres
@@ -13,56 +15,112 @@ type PostgreClient struct {
 	*sql.DB
 }
 
-var once sync.Once
+// Config of the connection pool to the postgres database.
+type Config struct {
+	// If DSN is set it's used as is and the connection fields are ignored.
+	DSN string
 
-const (
-	host     = "127.0.0.1"
-	port     = "5432"
-	user     = "postgres"
-	password = "postgres"
-	dbname   = "go-auth-database"
-)
+	Host     string
+	Port     string
+	User     string
+	Password string
+	DBName   string
+	// SSLMode is one of disable, require, verify-ca or verify-full.
+	SSLMode string
+	// SSLRootCert is the CA file used to verify the server (verify-ca and verify-full).
+	SSLRootCert string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
 
-// Starts the connection to our postgres database
-func NewPostgresClient() *PostgreClient {
-	once.Do(func() {
-		// Log db credentials
-		fmt.Println("Psql is using the following config:")
-		fmt.Printf("host: %15s\n", host)
-		fmt.Printf("port: %15s\n", port)
-		fmt.Printf("user: %15s\n", user)
-		fmt.Printf("password: %11s\n", password)
-		fmt.Printf("database: %11s\n", dbname)
-	})
-
-	// dsn = data source name
-	// The idea is that the parameters are variable according to the environment
-	dsn := "postgres://" + user + ":" + password + "@" + host + ":" + port + "/" + dbname + "?sslmode=disable"
+// Returns the config used for local development (See the Makefile).
+func DefaultConfig() Config {
+	return Config{
+		Host:            "127.0.0.1",
+		Port:            "5432",
+		User:            "postgres",
+		Password:        "postgres",
+		DBName:          "go-auth-database",
+		SSLMode:         "disable",
+		MaxOpenConns:    25,
+		MaxIdleConns:    25,
+		ConnMaxLifetime: time.Minute * 30,
+		ConnMaxIdleTime: time.Minute * 5,
+	}
+}
+
+// dsn = data source name
+func (c Config) dsn() (string, error) {
+	if c.DSN != "" {
+		return c.DSN, nil
+	}
+
+	switch c.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return "", fmt.Errorf("Invalid sslmode %s", c.SSLMode)
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	if c.SSLRootCert != "" {
+		query.Set("sslrootcert", c.SSLRootCert)
+	}
+
+	// url.URL escapes the user and password
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     c.Host + ":" + c.Port,
+		Path:     "/" + c.DBName,
+		RawQuery: query.Encode(),
+	}
+
+	return u.String(), nil
+}
+
+// Creates the pool of connections to our postgres database.
+//
+// It must be called once at the start of the application and the pool shared
+// by everyone, remember to Close it when the app stops.
+func NewPostgresClient(c Config) (*PostgreClient, error) {
+	if c.MaxOpenConns < 0 || c.MaxIdleConns < 0 {
+		return nil, errors.New("The limits of the connection pool can not be negative")
+	}
+
+	dsn, err := c.dsn()
+	if err != nil {
+		return nil, err
+	}
+
+	// Log db config, never the password
+	logger.Log().Infof(
+		"Psql is using the following config: host=%s port=%s user=%s database=%s sslmode=%s max_open_conns=%d max_idle_conns=%d",
+		c.Host, c.Port, c.User, c.DBName, c.SSLMode, c.MaxOpenConns, c.MaxIdleConns,
+	)
 
 	// Open method does not create the connection, it simply check if the arguments work properly
 	// That's why we MUST check with Ping() method if it's working!
 	db, err := sql.Open("postgres", dsn)
-	// Close DB after program exits
-	// defer db.Close()
-
 	if err != nil {
-		// If we can not connect to the database, log the error and close the app with panic
-		logger.Log().Errorf("Error opening the database. Reason: %v", err)
-		panic(err)
+		return nil, err
 	}
 
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+
 	// Check if the connection with the database is stable and alive
 	err = db.Ping()
-
 	if err != nil {
 		logger.Log().Errorf("Error with the connection with the database. Reason: %v", err)
+	} else {
+		logger.Log().Infof("Connected to %s succesfully!", c.DBName)
 	}
 
-	once.Do(func() {
-		// fmt.Printf("\nConnected to %s succesfully!\n", dbname)
-		logger.Log().Infof("Connected to %s succesfully!", dbname)
-	})
-
-	// As there are no errors, return the database connection
-	return &PostgreClient{db}
+	return &PostgreClient{db}, nil
 }
@@ -0,0 +1,27 @@
+package connection
+
+import (
+	"database/sql"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	_ "github.com/glebarez/go-sqlite" // Pure go driver for SQLite, no cgo needed
+)
+
+// Opens the embedded SQLite database stored on the file (":memory:" keeps it in memory)
+func NewSQLiteClient(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only supports one writer at a time
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	logger.Log().Infof("Connected to SQLite database %s succesfully!", path)
+
+	return db, nil
+}
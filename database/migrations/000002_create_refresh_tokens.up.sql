@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id SERIAL,
+    user_id INTEGER NOT NULL,
+    family_id VARCHAR(64) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    rotated_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    -- Define CONSTRAINTS
+    CONSTRAINT refresh_tokens_id_pk PRIMARY KEY (id),
+    CONSTRAINT refresh_tokens_token_hash_uk UNIQUE (token_hash),
+    CONSTRAINT refresh_tokens_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
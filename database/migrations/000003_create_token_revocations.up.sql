@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL,
+    -- Define CONSTRAINTS
+    CONSTRAINT revoked_tokens_jti_pk PRIMARY KEY (jti)
+);
+
+CREATE TABLE IF NOT EXISTS user_token_revocations (
+    user_id INTEGER NOT NULL,
+    revoked_before TIMESTAMP NOT NULL,
+    -- Define CONSTRAINTS
+    CONSTRAINT user_token_revocations_user_id_pk PRIMARY KEY (user_id),
+    CONSTRAINT user_token_revocations_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
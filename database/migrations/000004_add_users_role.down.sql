@@ -0,0 +1,3 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_ck;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
\ No newline at end of file
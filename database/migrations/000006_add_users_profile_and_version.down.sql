@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+
+ALTER TABLE users DROP COLUMN IF EXISTS bio;
+
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL,
+    user_id INTEGER NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    -- Define CONSTRAINTS
+    CONSTRAINT password_reset_tokens_id_pk PRIMARY KEY (id),
+    CONSTRAINT password_reset_tokens_token_hash_uk UNIQUE (token_hash),
+    CONSTRAINT password_reset_tokens_user_id_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_id_idx ON password_reset_tokens (user_id);
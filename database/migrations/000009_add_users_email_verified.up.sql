@@ -0,0 +1,5 @@
+-- The users created before the verification are considered verified, the new
+-- ones are not verified until they open the link sent to their email
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT TRUE;
+
+ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT FALSE;
@@ -0,0 +1 @@
+ALTER TABLE user_token_revocations DROP COLUMN IF EXISTS kept_jti;
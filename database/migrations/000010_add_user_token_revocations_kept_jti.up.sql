@@ -0,0 +1,3 @@
+-- The jti of the token that is not revoked with the others of the user, e.g.
+-- the new session of a password change
+ALTER TABLE user_token_revocations ADD COLUMN IF NOT EXISTS kept_jti VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,308 @@
+// Package migrations embeds the sql migrations of the postgres database and
+// applies them.
+//
+// The versions are tracked on the schema_migrations table, with the same
+// format used by the migrate CLI, so a database migrated by hand can be
+// managed by the app and the other way around.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+)
+
+// The migrations are compiled into the binary, so a deploy does not need the
+// sql files nor the migrate CLI.
+//
+//go:embed *.sql
+var files embed.FS
+
+// Key of the postgres advisory lock held while migrating, so that when many
+// replicas start at the same time only one of them applies the migrations.
+const lockKey = 4276430931
+
+// Names of the files: 000001_init_schema.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var ErrDirty = errors.New("The database is dirty, a migration failed half way and must be fixed by hand")
+
+// Migration is a pair of up and down sql files.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status of a migration on the database.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Applies the migrations to a postgres database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Returns a Migrator with the embedded migrations.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db, migrations}, nil
+}
+
+// Reads the migrations of the directory, sorted by version.
+//
+// Every version must have both the up and the down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid version on %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("The migration %d has two names, %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("The migration %d_%s must have an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+
+			logger.Log().Infof("Applying migration %d_%s", migration.Version, migration.Name)
+			if err := apply(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("Migration %d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Reverts the last steps migrations and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Version > current {
+				continue
+			}
+
+			// The version of the previous migration, 0 once everything is reverted
+			previous := int64(0)
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+
+			logger.Log().Infof("Reverting migration %d_%s", migration.Version, migration.Name)
+			if err := apply(ctx, conn, migration.Down, previous); err != nil {
+				return fmt.Errorf("Migration %d_%s failed: %v", migration.Version, migration.Name, err)
+			}
+			reverted++
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Returns the migrations and if they are applied or not.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	statuses := []Status{}
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		current, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			statuses = append(statuses, Status{migration, migration.Version <= current})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Returns an error if there are migrations that are not applied yet.
+//
+// It does not take the lock, so it can be used by the readiness check while
+// another replica is migrating.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	current, err := currentVersion(ctx, m.db)
+	if err != nil {
+		return err
+	}
+
+	latest := int64(0)
+	if len(m.migrations) > 0 {
+		latest = m.migrations[len(m.migrations)-1].Version
+	}
+
+	if current < latest {
+		return fmt.Errorf("The database is at version %d, the latest migration is %d", current, latest)
+	}
+
+	return nil
+}
+
+// Runs f holding the advisory lock, on a single connection because the lock
+// belongs to the session that takes it.
+func (m *Migrator) withLock(ctx context.Context, f func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Blocks until the replica that is migrating finishes
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("Could not take the migrations lock: %v", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+
+	q := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		dirty BOOLEAN NOT NULL
+	)`
+	if _, err := conn.ExecContext(ctx, q); err != nil {
+		return err
+	}
+
+	return f(conn)
+}
+
+// Common interface of sql.DB and sql.Conn
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Returns the version applied, 0 if there is none
+func currentVersion(ctx context.Context, conn querier) (int64, error) {
+	var version int64
+	var dirty bool
+
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if dirty {
+		return 0, fmt.Errorf("%w (Version %d)", ErrDirty, version)
+	}
+
+	return version, nil
+}
+
+// Runs the sql and stores the new version in the same transaction, so a
+// failed migration leaves the database as it was.
+func apply(ctx context.Context, conn *sql.Conn, query string, version int64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+
+	if version > 0 {
+		_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+// Verify that the embedded migrations are complete and sorted.
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(files)
+	if err != nil {
+		t.Fatalf("❌ Could not load the embedded migrations: %v", err)
+	}
+
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "init_schema" {
+		t.Fatalf("❌ Expected the first migration to be 000001_init_schema, got %+v", migrations)
+	}
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Errorf("❌ The migrations are not sorted: %d after %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+
+	t.Logf("✅ %d migrations embedded.", len(migrations))
+}
+
+// Verify that a migration without its down file is rejected.
+func TestLoadMissingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_init.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"000001_init.down.sql": {Data: []byte("DROP TABLE a;")},
+		"000002_more.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"README.md":            {Data: []byte("Not a migration")},
+	}
+
+	if _, err := Load(fsys); err == nil {
+		t.Errorf("❌ Expected the migration without down file to be rejected")
+	} else {
+		t.Log("✅ Incomplete migration rejected.")
+	}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
+)
+
+// The errors of the repository and the tokens, with the status, the code and
+// the detail sent to the client.
+func init() {
+	problem.Register(repository.ErrUserNotFound, http.StatusNotFound, problem.CodeUserNotFound, "User not found")
+	problem.Register(repository.ErrUserAlreadyExists, http.StatusConflict, problem.CodeUserAlreadyExists, "There is already a user with that username or email")
+	problem.Register(repository.ErrVersionMismatch, http.StatusConflict, problem.CodeVersionMismatch, "The user was modified by someone else, fetch it again")
+
+	problem.Register(auth.ErrRefreshTokenInvalid, http.StatusUnauthorized, problem.CodeInvalidRefreshToken, "Invalid refresh token")
+	problem.Register(auth.ErrRefreshTokenNotFound, http.StatusUnauthorized, problem.CodeInvalidRefreshToken, "Invalid refresh token")
+	problem.Register(auth.ErrRefreshTokenExpired, http.StatusUnauthorized, problem.CodeRefreshTokenExpired, "Refresh token has expired, log in again")
+	problem.Register(auth.ErrRefreshTokenReused, http.StatusUnauthorized, problem.CodeRefreshTokenReused, "Refresh token was already used, the session has been revoked")
+	problem.Register(auth.ErrTokenRevoked, http.StatusUnauthorized, problem.CodeTokenRevoked, "Token has been revoked")
+
+	problem.Register(auth.ErrResetTokenInvalid, http.StatusBadRequest, problem.CodeInvalidResetToken, "Invalid or already used reset token")
+	problem.Register(auth.ErrResetTokenNotFound, http.StatusBadRequest, problem.CodeInvalidResetToken, "Invalid or already used reset token")
+	problem.Register(auth.ErrResetTokenExpired, http.StatusBadRequest, problem.CodeResetTokenExpired, "Reset token has expired, ask for a new one")
+
+	problem.Register(auth.ErrVerificationTokenInvalid, http.StatusBadRequest, problem.CodeInvalidVerificationToken, "Invalid verification token")
+	problem.Register(auth.ErrVerificationTokenExpired, http.StatusBadRequest, problem.CodeVerificationTokenExpired, "Verification token has expired, ask for a new one")
+}
+
+var errNotOwner = problem.New(http.StatusForbidden, problem.CodeForbidden, "The account belongs to another user")
+
+// Returns the error sent when the body can not be decoded
+func invalidBody(err error) error {
+	return problem.Wrap(err, http.StatusBadRequest, problem.CodeInvalidBody, "Could not decode the request body")
+}
+
+// Returns the error sent when the id of the url is not a number
+func invalidID(err error) error {
+	return problem.Wrap(err, http.StatusBadRequest, problem.CodeInvalidParameter, "The id must be a number")
+}
+
+// Returns the error sent when the email or the password are wrong, it's the
+// same for both so that the client can not tell which one failed.
+func invalidCredentials(err error) error {
+	return problem.Wrap(err, http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid email or password")
+}
+
+// Returns the error sent when a field of the request is not valid, the
+// message of err is sent so it must be meant for the client.
+func validationFailed(err error) error {
+	return problem.Wrap(err, http.StatusBadRequest, problem.CodeValidationFailed, err.Error())
+}
+
+// Logs the error and sends it as a problem (See problem.Write), the internal
+// errors are sent without their details.
+func sendError(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Write(w, r, err)
+}
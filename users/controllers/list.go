@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
+)
+
+// Number of users of a page when the limit is not sent, and the maximum limit
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Body of the list of users
+type usersPage struct {
+	Data       []models.User `json:"data"`
+	Pagination pagination    `json:"pagination"`
+	Links      links         `json:"links"`
+}
+
+type pagination struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Urls of the current page and the next one, with the same filters
+type links struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+}
+
+// Reads the filters, the sort and the page of the query:
+//
+// ?username=ra&email=ra&created_from=2021-01-01T00:00:00Z&created_to=...&sort=-created_at&limit=20&cursor=...
+//
+// The sort is id or created_at, descending if it starts with "-".
+func parseListOptions(query url.Values) (repository.ListOptions, error) {
+	opts := repository.ListOptions{
+		UsernamePrefix: query.Get("username"),
+		EmailPrefix:    query.Get("email"),
+		SortBy:         repository.SortByID,
+		Limit:          defaultPageSize,
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return opts, invalidParameter(fmt.Sprintf("The limit must be a number between 1 and %d", maxPageSize))
+		}
+		opts.Limit = limit
+	}
+
+	if v := query.Get("sort"); v != "" {
+		opts.Desc = strings.HasPrefix(v, "-")
+		opts.SortBy = strings.TrimPrefix(v, "-")
+
+		if opts.SortBy != repository.SortByID && opts.SortBy != repository.SortByCreatedAt {
+			return opts, invalidParameter("The sort must be id or created_at, with a - for descending order")
+		}
+	}
+
+	dates := map[string]*time.Time{
+		"created_from": &opts.CreatedFrom,
+		"created_to":   &opts.CreatedTo,
+	}
+	for param, date := range dates {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, invalidParameter(fmt.Sprintf("The %s must be a RFC 3339 date, e.g. 2021-10-01T00:00:00Z", param))
+		}
+		*date = parsed
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := repository.DecodeCursor(v)
+		if err != nil {
+			return opts, invalidParameter("The cursor is not valid, use the next_cursor of the previous page")
+		}
+		opts.After = cursor
+	}
+
+	return opts, nil
+}
+
+// Returns the url of the request with the cursor, an empty cursor is the first page
+func pageLink(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	query.Del("cursor")
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// Returns the error sent when a parameter of the query is not valid
+func invalidParameter(detail string) error {
+	return problem.Wrap(errors.New(detail), http.StatusBadRequest, problem.CodeInvalidParameter, detail)
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/RamiroCuenca/go-jwt-auth/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Change the password of the authenticated user
+//
+// It must recieve the current_password and the new_password. Every token
+// issued to the user until now is revoked, so the other sessions (e.g. the
+// one of someone that stole the password) are closed. If keep_session is true
+// a new JWT and refresh token are sent, so the current session goes on.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// 1° Get the id from the claim of the token
+	claim, ok := auth.ClaimFromContext(r.Context())
+	if !ok {
+		sendError(w, r, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Missing token"))
+		return
+	}
+
+	// 2° Decode the json received
+	type changePasswordCMD struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+		KeepSession     bool   `json:"keep_session"`
+	}
+
+	cmd := changePasswordCMD{}
+
+	err := json.NewDecoder(r.Body).Decode(&cmd)
+	if err != nil {
+		sendError(w, r, invalidBody(err))
+		return
+	}
+
+	if cmd.CurrentPassword == "" || cmd.NewPassword == "" {
+		sendError(w, r, validationFailed(errors.New("Current password and new password are required")))
+		return
+	}
+
+	// 3° Check the current password, a stolen token is not enough to take the account
+	u, err := repo.GetByID(r.Context(), claim.UserId())
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	hashedPassword, err := repo.GetPassword(r.Context(), u.Id)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	err = utils.PasswordCheck(r.Context(), cmd.CurrentPassword, hashedPassword)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		sendError(w, r, problem.Wrap(err, http.StatusForbidden, problem.CodeWrongPassword, "The current password is not correct"))
+		return
+	}
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Could not check the password: %w", err))
+		return
+	}
+
+	// 4° Check the new password
+	if err := models.ValidatePasswordStrength(cmd.NewPassword, u); err != nil {
+		sendError(w, r, validationFailed(err))
+		return
+	}
+
+	if cmd.NewPassword == cmd.CurrentPassword {
+		sendError(w, r, validationFailed(errors.New("The new password must be different from the current one")))
+		return
+	}
+
+	// 5° Store the new password
+	hashedPassword, err = utils.PasswordHash(r.Context(), cmd.NewPassword)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Could not hash the password: %w", err))
+		return
+	}
+
+	err = repo.UpdatePassword(r.Context(), u.Id, hashedPassword)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// 6° Close every session of the user, the current one included. If the
+	// session is kept, they are closed once its new JWT is issued (See 7°)
+	if !cmd.KeepSession {
+		err = auth.RevokeAllForUser(u.Id)
+		if err != nil {
+			sendError(w, r, fmt.Errorf("Password changed but could not revoke the tokens: %w", err))
+			return
+		}
+
+		logger.FromContext(r.Context()).Infof("Password of user %d changed successfully! :)", u.Id)
+
+		handler.SendResponse(w, http.StatusOK, []byte(`{
+		"Message": "Password changed successfully, log in again"
+	}`), "")
+		return
+	}
+
+	// 7° Open a new session for the current client. The JWT is issued before
+	// the revocation and kept by its jti, the refresh token is issued after
+	// so that it's not revoked
+	token, err := auth.GenerateToken(r.Context(), u)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Password changed but could not generate the JWT: %w", err))
+		return
+	}
+
+	newClaim, err := auth.ValidateToken(r.Context(), token)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Password changed but could not read the new JWT: %w", err))
+		return
+	}
+
+	err = auth.RevokeAllForUserExcept(u.Id, newClaim.ID)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Password changed but could not revoke the tokens: %w", err))
+		return
+	}
+
+	logger.FromContext(r.Context()).Infof("Password of user %d changed successfully! :)", u.Id)
+
+	refreshToken, err := auth.IssueRefreshToken(u.Id)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Password changed but could not generate the refresh token: %w", err))
+		return
+	}
+
+	responseJson, _ := json.Marshal(tokensResponse{
+		Message:      "Password changed successfully",
+		JWT:          token,
+		RefreshToken: refreshToken,
+	})
+
+	handler.SendResponse(w, http.StatusOK, responseJson, token)
+}
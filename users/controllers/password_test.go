@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+)
+
+// Sends the password change with the token
+func doChangePassword(t *testing.T, token, body string) *httptest.ResponseRecorder {
+	claim, err := auth.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("❌ Could not validate the token: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/users/me/password", strings.NewReader(body))
+	r = r.WithContext(auth.NewContext(r.Context(), claim))
+
+	w := httptest.NewRecorder()
+	ChangePassword(w, r)
+	return w
+}
+
+// Verify that the password is changed and the old tokens are revoked.
+func TestChangePassword(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	oldToken := w.Header().Get("Token")
+
+	w = doChangePassword(t, oldToken, `{"current_password": "Blue-Sky-77", "new_password": "Correct-Horse-42", "keep_session": true}`)
+	if w.Code != http.StatusOK || w.Header().Get("Token") == "" {
+		t.Fatalf("❌ Could not change the password: %d %s", w.Code, w.Body.String())
+	}
+
+	if _, err := auth.ValidateToken(context.Background(), oldToken); err == nil {
+		t.Errorf("❌ The token issued before the change is still valid")
+	}
+	if _, err := auth.ValidateToken(context.Background(), w.Header().Get("Token")); err != nil {
+		t.Errorf("❌ The token of the kept session is not valid: %v", err)
+	}
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("❌ Logged in with the old password")
+	}
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Correct-Horse-42"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("❌ Could not log in with the new password: %d %s", w.Code, w.Body.String())
+	} else {
+		t.Log("✅ Password changed.")
+	}
+
+	u, _ := repo.GetByID(context.Background(), 1)
+	if u.PasswordChangedAt.IsZero() {
+		t.Errorf("❌ The date of the change was not stored")
+	}
+}
+
+// Verify that a wrong current password and weak passwords are rejected.
+func TestChangePasswordErrors(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	token := w.Header().Get("Token")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"wrong password", `{"current_password": "wrong", "new_password": "Correct-Horse-42"}`, http.StatusForbidden, problem.CodeWrongPassword},
+		{"short password", `{"current_password": "Blue-Sky-77", "new_password": "abc123"}`, http.StatusBadRequest, problem.CodeValidationFailed},
+		{"only letters", `{"current_password": "Blue-Sky-77", "new_password": "correcthorse"}`, http.StatusBadRequest, problem.CodeValidationFailed},
+		{"username", `{"current_password": "Blue-Sky-77", "new_password": "Ramiro2021"}`, http.StatusBadRequest, problem.CodeValidationFailed},
+		{"missing password", `{"current_password": "Blue-Sky-77"}`, http.StatusBadRequest, problem.CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		w := doChangePassword(t, token, tt.body)
+		p := problem.Problem{}
+		json.Unmarshal(w.Body.Bytes(), &p)
+
+		if w.Code != tt.status || p.Code != tt.code {
+			t.Errorf("❌ %s: expected %d %s, got %d %s", tt.name, tt.status, tt.code, w.Code, w.Body.String())
+		}
+	}
+
+	// The token is still valid, nothing was changed
+	if _, err := auth.ValidateToken(context.Background(), token); err != nil {
+		t.Errorf("❌ The token was revoked by a failed change: %v", err)
+	} else {
+		t.Log("✅ Invalid password changes rejected.")
+	}
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
+)
+
+// Content type of the JSON Merge Patch (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+// Fields that can be patched, with the function that sets them after checking them
+var patchableFields = map[string]func(u *models.User, v string) error{
+	"username": func(u *models.User, v string) error {
+		u.Username = v
+		return models.ValidateUsername(v)
+	},
+	"email": func(u *models.User, v string) error {
+		u.Email = v
+		return models.ValidateEmail(v)
+	},
+	"display_name": func(u *models.User, v string) error {
+		u.DisplayName = v
+		return models.ValidateDisplayName(v)
+	},
+	"bio": func(u *models.User, v string) error {
+		u.Bio = v
+		return models.ValidateBio(v)
+	},
+}
+
+// Fields of the user that can not be patched
+var readOnlyFields = map[string]string{
+	"id":         "The id can not be modified",
+	"password":   "The password can not be modified here, use /api/v1/users/me/password",
+	"role":       "The role can not be modified",
+	"created_at": "The creation date can not be modified",
+	"updated_at": "The update date can not be modified",
+	"version":    "The version can not be modified, send it on the If-Match header",
+}
+
+// Update some fields of a specific user
+//
+// The body is a JSON Merge Patch (RFC 7396): only the sent fields are
+// modified, and null removes the optional ones. If the If-Match header is
+// sent, the user is only updated if its ETag has not changed (412 otherwise).
+func PatchUser(w http.ResponseWriter, r *http.Request) {
+	// 1° Get the id from the request url
+	id, err := userID(r)
+	if err != nil {
+		sendError(w, r, invalidID(err))
+		return
+	}
+
+	// Regular users can only modify their own account
+	if !canAccessUser(r, id) {
+		sendError(w, r, errNotOwner)
+		return
+	}
+
+	// 2° Read the version that the client knows, 0 if it does not care
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// 3° Decode the patch
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != mergePatchContentType && contentType != "application/json" {
+		sendError(w, r, problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+			"The body must be a JSON Merge Patch ("+mergePatchContentType+")"))
+		return
+	}
+
+	patch := map[string]json.RawMessage{}
+
+	err = json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		sendError(w, r, invalidBody(err))
+		return
+	}
+
+	// 4° Fetch the current user and check that the client has its last version
+	u, err := repo.GetByID(r.Context(), id)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	if version != 0 && version != u.Version {
+		sendError(w, r, preconditionFailed(repository.ErrVersionMismatch))
+		return
+	}
+
+	// 5° Apply the patch, every field is checked
+	if len(patch) == 0 {
+		json, _ := json.Marshal(u)
+
+		w.Header().Set("ETag", etag(u))
+		handler.SendResponse(w, http.StatusOK, json, "")
+		return
+	}
+
+	email := u.Email
+
+	if fields := applyPatch(&u, patch); len(fields) > 0 {
+		err := problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Some fields are not valid")
+		err.Fields = fields
+		sendError(w, r, err)
+		return
+	}
+
+	// 6° Store the changes, they are rejected if someone else updated the user
+	// since it was fetched
+	err = repo.Update(r.Context(), &u)
+	if errors.Is(err, repository.ErrVersionMismatch) && version != 0 {
+		sendError(w, r, preconditionFailed(err))
+		return
+	}
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// A new email is not verified, send the link to verify it
+	if u.Email != email && !u.EmailVerified {
+		ctx := logger.NewContext(context.Background(), logger.FromContext(r.Context()))
+		runInBackground(func() {
+			sendVerificationEmail(ctx, u)
+		})
+	}
+
+	// 7° Send response, with the new ETag
+	json, _ := json.Marshal(u)
+
+	w.Header().Set("ETag", etag(u))
+	handler.SendResponse(w, http.StatusOK, json, "")
+}
+
+// Applies the merge patch to the user and returns the errors of each field
+func applyPatch(u *models.User, patch map[string]json.RawMessage) map[string]string {
+	fields := map[string]string{}
+
+	for field, raw := range patch {
+		if message, ok := readOnlyFields[field]; ok {
+			fields[field] = message
+			continue
+		}
+
+		set, ok := patchableFields[field]
+		if !ok {
+			fields[field] = "Unknown field"
+			continue
+		}
+
+		// null removes the field, it's the same as an empty string
+		var value *string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			fields[field] = "Must be a string"
+			continue
+		}
+		if value == nil {
+			value = new(string)
+		}
+
+		if err := set(u, strings.TrimSpace(*value)); err != nil {
+			fields[field] = err.Error()
+		}
+	}
+
+	return fields
+}
+
+// Returns the ETag of the user, its version
+func etag(u models.User) string {
+	return fmt.Sprintf(`"%d"`, u.Version)
+}
+
+// Returns the version of the If-Match header, 0 if it's not sent or it's *
+func ifMatchVersion(r *http.Request) (int64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0, nil
+	}
+
+	// The ETags are strong, but the weak ones sent by some proxies are accepted
+	v = strings.Trim(strings.TrimPrefix(v, "W/"), `"`)
+
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, preconditionFailed(fmt.Errorf("Invalid If-Match %s", r.Header.Get("If-Match")))
+	}
+
+	return version, nil
+}
+
+// Returns the error sent when the If-Match is not the current ETag of the user
+func preconditionFailed(err error) error {
+	return problem.Wrap(err, http.StatusPreconditionFailed, problem.CodePreconditionFailed,
+		"The user was modified since it was fetched, fetch it again to get its ETag")
+}
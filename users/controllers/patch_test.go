@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// Sends the merge patch of the user 1 as its owner
+func doPatch(t *testing.T, token, body, ifMatch string) *httptest.ResponseRecorder {
+	claim, err := auth.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("❌ Could not validate the token: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPatch, "/api/v2/users/1?id=1", strings.NewReader(body))
+	r.Header.Set("Content-Type", mergePatchContentType)
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	r = r.WithContext(auth.NewContext(r.Context(), claim))
+
+	w := httptest.NewRecorder()
+	PatchUser(w, r)
+	return w
+}
+
+// Verify that only the sent fields are updated and the ETag changes.
+func TestPatchUser(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	token := w.Header().Get("Token")
+
+	w = doPatch(t, token, `{"display_name": "Ramiro Cuenca", "bio": "Gopher"}`, `"1"`)
+	u := models.User{}
+	json.Unmarshal(w.Body.Bytes(), &u)
+
+	if w.Code != http.StatusOK || u.DisplayName != "Ramiro Cuenca" || u.Bio != "Gopher" || u.Username != "ramiro" {
+		t.Fatalf("❌ Could not patch the user: %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") != `"2"` {
+		t.Errorf("❌ Expected the ETag \"2\", got %s", w.Header().Get("ETag"))
+	}
+
+	// null removes the bio
+	w = doPatch(t, token, `{"bio": null}`, "")
+	u = models.User{}
+	json.Unmarshal(w.Body.Bytes(), &u)
+	if u.Bio != "" || u.DisplayName != "Ramiro Cuenca" {
+		t.Errorf("❌ Could not remove the bio: %s", w.Body.String())
+	} else {
+		t.Log("✅ User patched.")
+	}
+}
+
+// Verify that the stale writes, the conflicts and the invalid fields are rejected.
+func TestPatchUserErrors(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	token := w.Header().Get("Token")
+	doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "other", "email": "other@example.com", "password": "Blue-Sky-77"}`)
+
+	// The first patch changes the version, so the second one is stale
+	doPatch(t, token, `{"bio": "First"}`, `"1"`)
+
+	tests := []struct {
+		name    string
+		body    string
+		ifMatch string
+		status  int
+		code    string
+	}{
+		{"stale write", `{"bio": "Second"}`, `"1"`, http.StatusPreconditionFailed, problem.CodePreconditionFailed},
+		{"taken email", `{"email": "other@example.com"}`, "", http.StatusConflict, problem.CodeUserAlreadyExists},
+		{"invalid fields", `{"email": "invalid", "role": "admin", "username": null}`, "", http.StatusBadRequest, problem.CodeValidationFailed},
+		{"invalid body", `["bio"]`, "", http.StatusBadRequest, problem.CodeInvalidBody},
+	}
+
+	for _, tt := range tests {
+		w := doPatch(t, token, tt.body, tt.ifMatch)
+		p := problem.Problem{}
+		json.Unmarshal(w.Body.Bytes(), &p)
+
+		if w.Code != tt.status || p.Code != tt.code {
+			t.Errorf("❌ %s: expected %d %s, got %d %s", tt.name, tt.status, tt.code, w.Code, w.Body.String())
+		}
+		if tt.name == "invalid fields" && len(p.Errors) != 3 {
+			t.Errorf("❌ Expected an error for each field, got %v", p.Errors)
+		}
+	}
+
+	t.Log("✅ Invalid patches rejected.")
+}
+
+// Verify that a new email is verified again with the link sent to it.
+func TestPatchEmailSendsVerification(t *testing.T) {
+	sender := setupVerification(t, VerificationBlock)
+
+	doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	doRequest(VerifyEmail, http.MethodGet, lastVerificationLink(t, sender), "")
+	w := doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	token := w.Header().Get("Token")
+	sender.sent = nil
+
+	// Other fields don't send the link
+	doPatch(t, token, `{"bio": "Gopher"}`, "")
+	if len(sender.sent) != 0 {
+		t.Errorf("❌ A link was sent without changing the email")
+	}
+
+	w = doPatch(t, token, `{"email": "cuenca@example.com"}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("❌ Could not change the email: %d %s", w.Code, w.Body.String())
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "cuenca@example.com" {
+		t.Fatalf("❌ The link was not sent to the new email: %v", sender.sent)
+	}
+
+	doRequest(VerifyEmail, http.MethodGet, lastVerificationLink(t, sender), "")
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "cuenca@example.com", "password": "Blue-Sky-77"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("❌ Could not log in with the new email: %d %s", w.Code, w.Body.String())
+	} else {
+		t.Log("✅ New email verified with the link sent to it.")
+	}
+}
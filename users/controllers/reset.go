@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/mail"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/common/ratelimit"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
+	"github.com/RamiroCuenca/go-jwt-auth/utils"
+)
+
+// Sender of the emails with the reset tokens and the verification links
+var mailer mail.Sender = mail.NewLogSender()
+
+// Sets the sender of the emails used by the controllers.
+// It may be called from the main package at the start of the application.
+func SetMailer(s mail.Sender) {
+	mailer = s
+}
+
+// Limits of the password reset: emails sent to the same address, and requests
+// of the same client to /password/forgot and /password/reset
+var (
+	forgotEmailLimiter   = ratelimit.New(3, time.Hour)
+	passwordResetLimiter = ratelimit.New(10, time.Minute*15)
+)
+
+// The work that is running in background, so that it's not cut off when the
+// app stops (See WaitBackground)
+var background sync.WaitGroup
+
+// Runs the work that must not delay the response, tests replace it to run it
+// before the response is sent
+var runInBackground = func(f func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		f()
+	}()
+}
+
+// Waits until the work running in background (e.g. the emails) is done, or
+// returns the error of ctx if it ends first.
+// It may be called from the main package when the app stops, once the requests
+// are drained and before the storage is closed.
+func WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sends a password reset token to the email of the user
+//
+// It must recieve the email. The response is the same whether the email is
+// registered or not, and the token is generated and sent in the background so
+// that it takes the same time as well.
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// 1° Decode the json received
+	type forgotPasswordCMD struct {
+		Email string `json:"email"`
+	}
+
+	cmd := forgotPasswordCMD{}
+
+	err := json.NewDecoder(r.Body).Decode(&cmd)
+	if err != nil {
+		sendError(w, r, invalidBody(err))
+		return
+	}
+
+	cmd.Email = strings.TrimSpace(cmd.Email)
+	if cmd.Email == "" {
+		sendError(w, r, validationFailed(errors.New("Email is required")))
+		return
+	}
+
+	// 2° Check the limits of the client and of the email, the email is limited
+	// even if it's not registered so the limit does not tell it either
+	if !allow(w, r, passwordResetLimiter, "ip:"+clientIP(r)) ||
+		!allow(w, r, forgotEmailLimiter, "email:"+strings.ToLower(cmd.Email)) {
+		return
+	}
+
+	// 3° Generate and send the token, the request may end before it's done
+	ctx := logger.NewContext(context.Background(), logger.FromContext(r.Context()))
+	runInBackground(func() {
+		sendResetToken(ctx, cmd.Email)
+	})
+
+	// 4° Send response
+	handler.SendResponse(w, http.StatusAccepted, []byte(`{
+		"Message": "If the email is registered, a password reset token was sent to it"
+	}`), "")
+}
+
+// Generates a reset token for the owner of the email and sends it, the
+// unknown emails are ignored
+func sendResetToken(ctx context.Context, email string) {
+	log := logger.FromContext(ctx)
+
+	u, err := repo.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		log.Info("Password reset asked for an email that is not registered")
+		return
+	}
+	if err != nil {
+		log.Errorf("Could not fetch the user of the password reset. Reason: %v", err)
+		return
+	}
+
+	token, expiresAt, err := auth.IssueResetToken(u.Id)
+	if err != nil {
+		log.Errorf("Could not generate the reset token of user %d. Reason: %v", u.Id, err)
+		return
+	}
+
+	err = mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your account. If it was you, send this
+token to /api/v1/password/reset with your new password:
+
+%s
+
+The token can be used only once and expires at %s. If it was not you,
+ignore this email, your password has not changed.
+`, u.Username, token, expiresAt.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		log.Errorf("Could not send the reset token to user %d. Reason: %v", u.Id, err)
+		return
+	}
+
+	log.Infof("Reset token sent to user %d successfully! :)", u.Id)
+}
+
+// Sets a new password with a reset token
+//
+// It must recieve the token sent by /password/forgot and the new_password.
+// The token can only be used once, and every session of the user is closed.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	// 1° Decode the json received
+	type resetPasswordCMD struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	cmd := resetPasswordCMD{}
+
+	err := json.NewDecoder(r.Body).Decode(&cmd)
+	if err != nil {
+		sendError(w, r, invalidBody(err))
+		return
+	}
+
+	if cmd.Token == "" || cmd.NewPassword == "" {
+		sendError(w, r, validationFailed(errors.New("Token and new password are required")))
+		return
+	}
+
+	if !allow(w, r, passwordResetLimiter, "ip:"+clientIP(r)) {
+		return
+	}
+
+	// 2° Check the token and the new password, the token is not used yet so
+	// that the user can try again with another password
+	userId, err := auth.CheckResetToken(cmd.Token)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	u, err := repo.GetByID(r.Context(), userId)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	if err := models.ValidatePasswordStrength(cmd.NewPassword, u); err != nil {
+		sendError(w, r, validationFailed(err))
+		return
+	}
+
+	hashedPassword, err := utils.PasswordHash(r.Context(), cmd.NewPassword)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Could not hash the password: %w", err))
+		return
+	}
+
+	// 3° Use the token, if two requests send it only one gets here
+	_, err = auth.ConsumeResetToken(cmd.Token)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// 4° Store the new password and close every session of the user
+	err = repo.UpdatePassword(r.Context(), u.Id, hashedPassword)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	err = auth.RevokeAllForUser(u.Id)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Password reset but could not revoke the tokens: %w", err))
+		return
+	}
+
+	logger.FromContext(r.Context()).Infof("Password of user %d reset successfully! :)", u.Id)
+
+	handler.SendResponse(w, http.StatusOK, []byte(`{
+		"Message": "Password reset successfully, log in again"
+	}`), "")
+}
+
+// Records the request on the limiter, if the key reached its limit it sends
+// a 429 with the seconds to wait on Retry-After and returns false
+func allow(w http.ResponseWriter, r *http.Request, l *ratelimit.Limiter, key string) bool {
+	ok, retryAfter := l.Allow(key)
+	if ok {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	sendError(w, r, problem.New(http.StatusTooManyRequests, problem.CodeTooManyRequests,
+		"Too many requests, try again later"))
+	return false
+}
+
+// Proxies in front of the app, the limits of each client need the address
+// that they forward
+var trustedProxies handler.TrustedProxies
+
+// Sets the reverse proxies whose X-Forwarded-For is trusted.
+// It may be called from the main package at the start of the application.
+func SetTrustedProxies(p handler.TrustedProxies) {
+	trustedProxies = p
+}
+
+// Returns the ip of the client that sent the request
+func clientIP(r *http.Request) string {
+	return trustedProxies.ClientIP(r)
+}
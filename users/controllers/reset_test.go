@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/mail"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/common/ratelimit"
+)
+
+// Sender that keeps the emails instead of sending them
+type fakeSender struct {
+	sent []mail.Message
+}
+
+func (s *fakeSender) Send(ctx context.Context, m mail.Message) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+// Sender that keeps the emails and also logs them as the log driver
+type loggedSender struct {
+	fakeSender
+}
+
+func (s *loggedSender) Send(ctx context.Context, m mail.Message) error {
+	s.fakeSender.Send(ctx, m)
+	return mail.NewLogSender().Send(ctx, m)
+}
+
+// A reset token, 32 random bytes encoded as base64 url
+var resetTokenPattern = regexp.MustCompile(`[A-Za-z0-9_-]{43}`)
+
+// Sets up the controllers with a fake sender, the limits start empty
+func setupReset(t *testing.T) *fakeSender {
+	setupControllers(t)
+
+	sender := &fakeSender{}
+	SetMailer(sender)
+	forgotEmailLimiter = ratelimit.New(3, time.Hour)
+	passwordResetLimiter = ratelimit.New(10, time.Minute*15)
+
+	t.Cleanup(func() {
+		SetMailer(mail.NewLogSender())
+	})
+
+	return sender
+}
+
+// Verify that the reset token never reaches the logs with the log driver.
+func TestResetTokenNotLogged(t *testing.T) {
+	setupReset(t)
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := logger.Init(logger.Config{Format: "json", Level: "debug", File: path}); err != nil {
+		t.Fatalf("❌ Could not init the logger: %v", err)
+	}
+	t.Cleanup(func() { logger.InitZapLogger() })
+
+	sender := &loggedSender{}
+	SetMailer(sender)
+
+	doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	sender.sent = nil // The verification email
+
+	doRequest(ForgotPassword, http.MethodPost, "/api/v1/password/forgot", `{"email": "ramiro@example.com"}`)
+	if len(sender.sent) != 1 {
+		t.Fatalf("❌ The reset token was not sent")
+	}
+	token := resetTokenPattern.FindString(sender.sent[0].Body)
+
+	data, _ := ioutil.ReadFile(path)
+	logs := string(data)
+
+	if !strings.Contains(logs, `"to":"ramiro@example.com"`) {
+		t.Fatalf("❌ The email was not logged:\n%s", logs)
+	}
+	if token == "" || strings.Contains(logs, token) {
+		t.Errorf("❌ The reset token %q was logged:\n%s", token, logs)
+	} else {
+		t.Log("✅ Reset token not logged.")
+	}
+}
+
+// Verify that the token sent by email sets the new password.
+func TestResetPassword(t *testing.T) {
+	sender := setupReset(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	oldToken := w.Header().Get("Token")
+	sender.sent = nil // The verification email
+
+	w = doRequest(ForgotPassword, http.MethodPost, "/api/v1/password/forgot", `{"email": "ramiro@example.com"}`)
+	if w.Code != http.StatusAccepted || len(sender.sent) != 1 {
+		t.Fatalf("❌ The reset token was not sent: %d %s", w.Code, w.Body.String())
+	}
+	if sender.sent[0].To != "ramiro@example.com" {
+		t.Errorf("❌ The email was sent to %s", sender.sent[0].To)
+	}
+
+	token := resetTokenPattern.FindString(sender.sent[0].Body)
+
+	w = doRequest(ResetPassword, http.MethodPost, "/api/v1/password/reset", `{"token": "`+token+`", "new_password": "Correct-Horse-42"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("❌ Could not reset the password: %d %s", w.Code, w.Body.String())
+	}
+
+	if _, err := auth.ValidateToken(context.Background(), oldToken); err == nil {
+		t.Errorf("❌ The token issued before the reset is still valid")
+	}
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Correct-Horse-42"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("❌ Could not log in with the new password: %d %s", w.Code, w.Body.String())
+	}
+
+	// The token can not be used twice
+	w = doRequest(ResetPassword, http.MethodPost, "/api/v1/password/reset", `{"token": "`+token+`", "new_password": "Another-Horse-43"}`)
+	if code := problemCode(w); w.Code != http.StatusBadRequest || code != problem.CodeInvalidResetToken {
+		t.Errorf("❌ Expected the used token to be rejected, got %d %s", w.Code, code)
+	} else {
+		t.Log("✅ Password reset with the token sent by email.")
+	}
+}
+
+// Verify that the response does not tell whether the email is registered.
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	sender := setupReset(t)
+
+	doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	sender.sent = nil // The verification email
+
+	known := doRequest(ForgotPassword, http.MethodPost, "/api/v1/password/forgot", `{"email": "ramiro@example.com"}`)
+	unknown := doRequest(ForgotPassword, http.MethodPost, "/api/v1/password/forgot", `{"email": "nobody@example.com"}`)
+
+	if known.Code != unknown.Code || known.Body.String() != unknown.Body.String() {
+		t.Errorf("❌ The responses are different: %d %s / %d %s", known.Code, known.Body.String(), unknown.Code, unknown.Body.String())
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("❌ Expected only one email, got %d", len(sender.sent))
+	} else {
+		t.Log("✅ Same response for registered and unknown emails.")
+	}
+}
+
+// Verify that a weak password does not spend the token.
+func TestResetPasswordWeakPassword(t *testing.T) {
+	sender := setupReset(t)
+
+	doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	sender.sent = nil // The verification email
+	doRequest(ForgotPassword, http.MethodPost, "/api/v1/password/forgot", `{"email": "ramiro@example.com"}`)
+	token := resetTokenPattern.FindString(sender.sent[0].Body)
+
+	w := doRequest(ResetPassword, http.MethodPost, "/api/v1/password/reset", `{"token": "`+token+`", "new_password": "short"}`)
+	if code := problemCode(w); code != problem.CodeValidationFailed {
+		t.Errorf("❌ Expected a validation error, got %d %s", w.Code, code)
+	}
+
+	w = doRequest(ResetPassword, http.MethodPost, "/api/v1/password/reset", `{"token": "`+token+`", "new_password": "Correct-Horse-42"}`)
+	if w.Code != http.StatusOK {
+		t.Errorf("❌ The token was spent by the weak password: %d %s", w.Code, w.Body.String())
+	} else {
+		t.Log("✅ Weak password rejected without spending the token.")
+	}
+}
+
+// Verify that the emails sent to an address are limited.
+func TestForgotPasswordRateLimit(t *testing.T) {
+	sender := setupReset(t)
+
+	doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	sender.sent = nil // The verification email
+
+	for i := 0; i < 3; i++ {
+		doRequest(ForgotPassword, http.MethodPost, "/api/v1/password/forgot", `{"email": "ramiro@example.com"}`)
+	}
+
+	// The limit does not depend on the case of the email
+	w := doRequest(ForgotPassword, http.MethodPost, "/api/v1/password/forgot", `{"email": "Ramiro@Example.com"}`)
+
+	if code := problemCode(w); w.Code != http.StatusTooManyRequests || code != problem.CodeTooManyRequests {
+		t.Errorf("❌ Expected a 429, got %d %s", w.Code, code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Errorf("❌ Retry-After was not sent")
+	}
+	if len(sender.sent) != 3 {
+		t.Errorf("❌ Expected 3 emails, got %d", len(sender.sent))
+	} else {
+		t.Log("✅ Reset emails limited per address.")
+	}
+}
+
+// Returns the code of the problem sent on the response
+func problemCode(w *httptest.ResponseRecorder) string {
+	p := problem.Problem{}
+	json.Unmarshal(w.Body.Bytes(), &p)
+
+	return p.Code
+}
+
+// Verify that the clients behind a trusted proxy have their own limit.
+func TestForgotPasswordBehindProxy(t *testing.T) {
+	setupReset(t)
+
+	proxies, _ := handler.ParseTrustedProxies([]string{"10.0.0.1"})
+	SetTrustedProxies(proxies)
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	forgot := func(client string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/password/forgot", strings.NewReader(`{"email": "nobody@example.com"}`))
+		r.RemoteAddr = "10.0.0.1:4000"
+		r.Header.Set("X-Forwarded-For", client)
+		w := httptest.NewRecorder()
+		ForgotPassword(w, r)
+		return w.Code
+	}
+
+	// The same email is sent every time, only the limit of the clients counts
+	forgotEmailLimiter = ratelimit.New(100, time.Hour)
+
+	for i := 0; i < 10; i++ {
+		forgot("198.51.100.1")
+	}
+
+	if code := forgot("198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("❌ Expected the client to reach its limit, got %d", code)
+	}
+	if code := forgot("198.51.100.2"); code != http.StatusAccepted {
+		t.Errorf("❌ Another client behind the proxy was limited: %d", code)
+	} else {
+		t.Log("✅ Each client behind the proxy has its own limit.")
+	}
+}
+
+// Verify that the shutdown waits for the work running in background.
+func TestWaitBackground(t *testing.T) {
+	// The tests run the work before the response, this one needs the real one
+	tracked := runInBackground
+	setupControllers(t)
+	runInBackground = tracked
+
+	release := make(chan struct{})
+	runInBackground(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	if err := WaitBackground(ctx); err == nil {
+		t.Fatalf("❌ The wait ended before the work")
+	}
+
+	close(release)
+	if err := WaitBackground(context.Background()); err != nil {
+		t.Errorf("❌ Could not wait for the work: %v", err)
+	} else {
+		t.Log("✅ Work in background waited.")
+	}
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+)
+
+// Exchanges a refresh token for a fresh JWT
+//
+// It must recieve the refresh_token as parameter. The refresh token is rotated,
+// so the client must store the new one that is sent on the response.
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	// 1° Decode the json received
+	type refreshTokenCMD struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	cmd := refreshTokenCMD{}
+
+	err := json.NewDecoder(r.Body).Decode(&cmd)
+	if err != nil {
+		sendError(w, r, invalidBody(err))
+		return
+	}
+
+	if cmd.RefreshToken == "" {
+		sendError(w, r, validationFailed(errors.New("Refresh token is required")))
+		return
+	}
+
+	// 2° Rotate the refresh token, it returns the owner of the token
+	userId, refreshToken, err := auth.RotateRefreshToken(cmd.RefreshToken)
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		logger.FromContext(r.Context()).Warnf("Refresh token reuse detected, session revoked")
+	}
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// 3° Fetch the user so that we can generate the JWT
+	u, err := repo.GetByID(r.Context(), userId)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// The sessions opened before the policy was block can't go on either
+	if verificationPolicy == VerificationBlock && !u.EmailVerified {
+		sendError(w, r, emailNotVerified())
+		return
+	}
+
+	// 4° Generate the JWT
+	token, err := auth.GenerateToken(r.Context(), u)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Could not generate the JWT: %w", err))
+		return
+	}
+	logger.FromContext(r.Context()).Info("JWT refreshed successfully! :)")
+
+	// 5° Send the response
+	responseJson, _ := json.Marshal(tokensResponse{
+		Message:      "Token refreshed successfully",
+		JWT:          token,
+		RefreshToken: refreshToken,
+	})
+
+	handler.SendResponse(w, http.StatusOK, responseJson, token)
+}
+
+// Log out the user
+//
+// Revokes the JWT sent through the headers. If the refresh_token is sent on the
+// body, its session is revoked as well so it can not be exchanged anymore.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	// 1° Get the claim of the token that is being revoked
+	claim, ok := auth.ClaimFromContext(r.Context())
+	if !ok {
+		sendError(w, r, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Missing token"))
+		return
+	}
+
+	// 2° Decode the json received, the body is optional
+	type logoutCMD struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	cmd := logoutCMD{}
+
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&cmd)
+		if err != nil {
+			sendError(w, r, invalidBody(err))
+			return
+		}
+	}
+
+	// 3° Revoke the tokens
+	err := auth.RevokeToken(claim)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Could not revoke the token: %w", err))
+		return
+	}
+
+	if cmd.RefreshToken != "" {
+		err = auth.RevokeRefreshToken(cmd.RefreshToken, claim.UserId())
+		if errors.Is(err, auth.ErrRefreshTokenInvalid) {
+			// The token belongs to another user, the logout goes on without it
+			logger.FromContext(r.Context()).Warnf("User %d sent the refresh token of another user on the logout", claim.UserId())
+			err = nil
+		}
+		if err != nil {
+			sendError(w, r, fmt.Errorf("Could not revoke the refresh token: %w", err))
+			return
+		}
+	}
+
+	logger.FromContext(r.Context()).Infof("User logged out successfully! :)")
+
+	// 4° Send the response
+	handler.SendResponse(w, http.StatusOK, []byte(`{
+		"Message": "User logged out successfully"
+	}`), "")
+}
+
+// Revoke every token of a specific user by id
+//
+// Every JWT and refresh token issued to the user until now stops working.
+func RevokeUserTokens(w http.ResponseWriter, r *http.Request) {
+	// 1° Get the id from request url and convert it to int
+	urlParam := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(urlParam)
+	if err != nil {
+		sendError(w, r, invalidID(err))
+		return
+	}
+
+	// 2° The user must exist, its revocation references it
+	_, err = repo.GetByID(r.Context(), int64(id))
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// 3° Revoke the tokens
+	err = auth.RevokeAllForUser(int64(id))
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Could not revoke the tokens of the user: %w", err))
+		return
+	}
+
+	logger.FromContext(r.Context()).Infof("Tokens of user %d revoked successfully! :)", id)
+
+	// 4° Send the response
+	responseJson := fmt.Sprintf(`{
+		"Message": "Tokens revoked successfully",
+		"UserId": %d
+	}`, id)
+
+	handler.SendResponse(w, http.StatusOK, []byte(responseJson), "")
+}
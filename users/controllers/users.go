@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,286 +11,279 @@ import (
 	"github.com/RamiroCuenca/go-jwt-auth/auth"
 	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
 	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
-	"github.com/RamiroCuenca/go-jwt-auth/database/connection"
+	"github.com/RamiroCuenca/go-jwt-auth/common/metrics"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
 	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
 	"github.com/RamiroCuenca/go-jwt-auth/utils"
-	"github.com/lib/pq"
+	"github.com/go-chi/chi"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// Repository where the users are persisted
+var repo repository.UserRepository
+
+// Sets the repository used by the controllers.
+// It may be called from the main package at the start of the application.
+func SetRepository(r repository.UserRepository) {
+	repo = r
+}
+
+// Body of the responses that issue tokens (Sign up, login, refresh and the
+// password change), the fields that are not set are omitted
+type tokensResponse struct {
+	Message      string
+	User         *models.User `json:",omitempty"`
+	Username     string       `json:",omitempty"`
+	JWT          string       `json:",omitempty"`
+	RefreshToken string       `json:",omitempty"`
+}
+
+// Body of the response of the deleted user
+type deletedUserResponse struct {
+	Message     string      `json:"message"`
+	DeletedUser models.User `json:"deleted_user"`
+}
+
 // Registers a new user account
 //
 // It must recieve username, email and password as parameters (All strings...).
 func SignUp(w http.ResponseWriter, r *http.Request) {
+	// The result of the sign up is counted on the metrics, any failure that
+	// is not set below is an internal error
+	result := "error"
+	defer func() { metrics.ObserveSignUp(result) }()
+
 	// 1° Decode the json received on an User object
 	u := models.User{}
 
 	err := json.NewDecoder(r.Body).Decode(&u)
 	if err != nil {
-		sendError(w, http.StatusBadRequest, err, "Could not decode request body")
+		result = "invalid_request"
+		sendError(w, r, invalidBody(err))
 		return
 	}
 
 	// Check if user fields are valid
 	err = models.Check(u)
 	if err != nil {
-		sendError(w, http.StatusBadRequest, err, err.Error())
+		result = "invalid_request"
+		sendError(w, r, validationFailed(err))
 		return
 	}
 
+	// New users are always regular users, admins are promoted on the database,
+	// and their email is not verified
+	u.Role = models.RoleUser
+	u.EmailVerified = false
+
 	// Hash the password and replace it on the User field
-	u.Password, err = utils.PasswordHash(u.Password)
+	u.Password, err = utils.PasswordHash(r.Context(), u.Password)
+	if err != nil {
+		sendError(w, r, fmt.Errorf("Could not hash the password: %w", err))
+		return
+	}
+
+	// 2° Store the user, the repository assigns its id and creation date
+	err = repo.Create(r.Context(), &u)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not hash the password")
+		if errors.Is(err, repository.ErrUserAlreadyExists) {
+			result = "already_exists"
+		}
+		sendError(w, r, err)
 		return
 	}
+	logger.FromContext(r.Context()).Infof("User created successfully! :)")
 
-	// 2° Create the sql statement and prepare null fields
-	q := `
-	INSERT INTO users (username, email, hashed_password, created_at)
-	VALUES ($1, $2, $3, now()) 
-	RETURNING id, created_at
-	`
+	// The hashed password must not be sent on the response
+	u.Password = ""
 
-	// 3° Initialize database connection
-	db := connection.NewPostgresClient()
-	// defer db.Close()
+	// 3° Send the link that verifies the email
+	ctx := logger.NewContext(context.Background(), logger.FromContext(r.Context()))
+	runInBackground(func() {
+		sendVerificationEmail(ctx, u)
+	})
 
-	// 4° Start a transaction
-	tx, err := db.Begin()
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start the transaction")
+	// Location is the url of the new user
+	w.Header().Set("Location", fmt.Sprintf("/api/v2/users/%d", u.Id))
+
+	// Users can't log in until they verify the email, so they get no tokens
+	if verificationPolicy == VerificationBlock {
+		responseJson, _ := json.Marshal(tokensResponse{
+			Message: "User created successfully, verify the email to log in",
+			User:    &u,
+		})
+
+		result = "success"
+		handler.SendResponse(w, http.StatusCreated, responseJson, "")
 		return
 	}
 
-	// 5° Prepare the transaction
-	stmt, err := tx.Prepare(q)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start the transaction")
-		tx.Rollback()
-		return
-	}
-	defer stmt.Close()
-
-	// 6° Execute the query and assign the returned id to the Note object
-	// We will use QueryRow because the exec method returns two methods that are
-	// not compatible with psql!
-	err = stmt.QueryRow(
-		u.Username,
-		u.Email,
-		u.Password,
-	).Scan(
-		&u.Id,
-		&u.CreatedAt,
-	)
+	// 4° As the user is valid, generate a JWT
+	token, err := auth.GenerateToken(r.Context(), u)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not create the user")
-		tx.Rollback()
+		sendError(w, r, fmt.Errorf("User created but could not generate the JWT: %w", err))
 		return
 	}
+	logger.FromContext(r.Context()).Info("JWT generated successfully! :)")
 
-	// 7° As there are no errors, commit the transaction
-	tx.Commit()
-	logger.Log().Infof("User created successfully! :)")
-
-	// 8° As the user is valid, generate a JWT
-	token, err := auth.GenerateToken(u)
+	// Also generate the refresh token so that the client does not need to
+	// send the password again once the JWT expires
+	refreshToken, err := auth.IssueRefreshToken(u.Id)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "User created successfully but error generating JWT, try loging in...")
-		tx.Rollback()
+		sendError(w, r, fmt.Errorf("User created but could not generate the refresh token: %w", err))
 		return
 	}
-	logger.Log().Info("JWT generated successfully! :)")
-
-	// 9° If the token was generated successfully, create a Json to send a response
-	// Encode the User into a JSON object
-	userJson, _ := json.Marshal(u)
 
-	responseJson := fmt.Sprintf(`{
-		"Message": "User created successfully",
-		"User": %s,
-		"JWT": "%s"
-	}`, userJson, token)
+	// 5° If the token was generated successfully, create a Json to send a response
+	responseJson, _ := json.Marshal(tokensResponse{
+		Message:      "User created successfully",
+		User:         &u,
+		JWT:          token,
+		RefreshToken: refreshToken,
+	})
 
-	// 9° Send the response
-	handler.SendResponse(w, http.StatusCreated, []byte(responseJson), token)
+	// 6° Send the response
+	result = "success"
+	handler.SendResponse(w, http.StatusCreated, responseJson, token)
 }
 
 // Log in an existing user
 //
 // It must recieve email and password as parameters (Both strings...).
 func SignIn(w http.ResponseWriter, r *http.Request) {
+	// The result of the login is counted on the metrics, any failure that
+	// is not set below is an internal error
+	result := "error"
+	defer func() { metrics.ObserveSignIn(result) }()
+
 	// 1° Decode the json received on an User object
 	type loginUserCMD struct {
-		Username       string `json:"username"`
-		Email          string `json:"email"`
-		Password       string `json:"password"`
-		HashedPassword string `json:"hashed_password"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
 	}
 
-	u := loginUserCMD{}
+	cmd := loginUserCMD{}
 
-	err := json.NewDecoder(r.Body).Decode(&u)
+	err := json.NewDecoder(r.Body).Decode(&cmd)
 	if err != nil {
-		sendError(w, http.StatusBadRequest, err, "Could not decode request body")
+		result = "invalid_request"
+		sendError(w, r, invalidBody(err))
 		return
 	}
 
 	// Check if user fields are valid
-	if u.Email == "" || u.Password == "" {
-		sendError(w, http.StatusBadRequest, errors.New("Email and Password are required"), "Email and Password are required")
+	if cmd.Email == "" || cmd.Password == "" {
+		result = "invalid_request"
+		sendError(w, r, validationFailed(errors.New("Email and Password are required")))
 		return
 	}
 
-	// 2° Create the sql statement and prepare null fields
-	q := `
-	SELECT username, hashed_password FROM users
-	WHERE email = $1
-	`
-
-	// 3° Initialize database connection
-	db := connection.NewPostgresClient()
-	// defer db.Close()
+	// 2° Fetch the user, it includes the hashed password
+	u, err := repo.GetByEmail(r.Context(), cmd.Email)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		// Compare the password anyway, otherwise the unknown emails would be
+		// answered faster than the wrong passwords
+		utils.PasswordCheckDummy(r.Context(), cmd.Password)
 
-	// 4° Start a transaction
-	tx, err := db.Begin()
+		result = "user_not_found"
+		sendError(w, r, invalidCredentials(err))
+		return
+	}
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start the transaction")
+		sendError(w, r, err)
 		return
 	}
 
-	// 5° Prepare the transaction
-	stmt, err := tx.Prepare(q)
+	// 3° Compare password received and hashed password from the server
+	err = utils.PasswordCheck(r.Context(), cmd.Password, u.Password)
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		result = "wrong_password"
+		sendError(w, r, invalidCredentials(err))
+		return
+	}
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start the transaction")
-		tx.Rollback()
+		sendError(w, r, fmt.Errorf("Could not check the password: %w", err))
 		return
 	}
-	defer stmt.Close()
 
-	// 6° Execute the query and assign the returned id to the Note object
-	// We will use QueryRow because the exec method returns two methods that are
-	// not compatible with psql!
-	err = stmt.QueryRow(
-		u.Email,
-	).Scan(
-		&u.Username,
-		&u.HashedPassword,
-	)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "User not found")
-		tx.Rollback()
+	// The password is right, so telling that the email is not verified does
+	// not reveal anything
+	if verificationPolicy == VerificationBlock && !u.EmailVerified {
+		result = "email_not_verified"
+		sendError(w, r, emailNotVerified())
 		return
 	}
 
-	// 7° As there are no errors, commit the transaction
-	tx.Commit()
+	logger.FromContext(r.Context()).Infof("User logged successfully! :)")
 
-	// 8° Compare password received and hashed password from the server
-	err = utils.PasswordCheck(u.Password, u.HashedPassword)
+	// 4° As the user is valid, generate a JWT
+	token, err := auth.GenerateToken(r.Context(), u)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Incorrect password")
-		tx.Rollback()
+		sendError(w, r, fmt.Errorf("Could not generate the JWT: %w", err))
 		return
 	}
+	logger.FromContext(r.Context()).Info("JWT generated successfully! :)")
 
-	logger.Log().Infof("User logged successfully! :)")
-
-	// 8° As the user is valid, generate a JWT
-	user := models.User{
-		Username: u.Username,
-		Email:    u.Email,
-		Password: u.HashedPassword,
-	}
-	token, err := auth.GenerateToken(user)
+	refreshToken, err := auth.IssueRefreshToken(u.Id)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "User logged successfully but error generating JWT, try loging in again...")
-		tx.Rollback()
+		sendError(w, r, fmt.Errorf("Could not generate the refresh token: %w", err))
 		return
 	}
-	logger.Log().Info("JWT generated successfully! :)")
 
-	// 9° If the token was generated successfully, create a Json to send a response
-	// Encode the User into a JSON object
-	responseJson := fmt.Sprintf(`{
-		"Message": "User logged in successfully",
-		"Username": %s,
-		"JWT": "%s"
-	}`, u.Username, token)
+	// 5° If the token was generated successfully, create a Json to send a response
+	responseJson, _ := json.Marshal(tokensResponse{
+		Message:      "User logged in successfully",
+		Username:     u.Username,
+		JWT:          token,
+		RefreshToken: refreshToken,
+	})
 
-	// 9° Send the response
-	handler.SendResponse(w, http.StatusCreated, []byte(responseJson), token)
+	// 6° Send the response
+	result = "success"
+	handler.SendResponse(w, http.StatusOK, responseJson, token)
 }
 
-// Get all users
+// Get a page of users
 //
-// The user should be authenticated so it must sent the jwt through the headers
+// The user should be authenticated so it must sent the jwt through the headers.
+// The users can be filtered and sorted, the next page is fetched with the
+// cursor of the response (See parseListOptions).
 func ReadAll(w http.ResponseWriter, r *http.Request) {
-	// 1° Create the sql statement and prepare null fields
-	q := `SELECT id, username, email, created_at, updated_at FROM users`
-
-	// 2° Initialize the connection to the database and start a transaction
-	db := connection.NewPostgresClient()
-
-	tx, err := db.Begin()
+	// 1° Read the filters, the sort and the page from the query
+	opts, err := parseListOptions(r.URL.Query())
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start the transaction")
+		sendError(w, r, err)
 		return
 	}
 
-	// 3° Prepare the transaction, remember to close it
-	stmt, err := tx.Prepare(q)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not prepare the transaction")
-		tx.Rollback()
-		return
-	}
-	defer stmt.Close()
+	// 2° Fetch one more user than the limit, so we know if there's a next page
+	limit := opts.Limit
+	opts.Limit++
 
-	// 4° Execute the query and assign each value to a user object and the append it to an array
-	// We will use QueryRow because the exec method returns two methods that are
-	// not compatible with psql!
-	rows, err := stmt.Query()
+	usersArr, err := repo.List(r.Context(), opts)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not get rows")
-		tx.Rollback()
+		sendError(w, r, fmt.Errorf("Could not list the users: %w", err))
 		return
 	}
-	defer rows.Close()
-
-	// 5° Create a users arr and assign each value
-	var usersArr []models.User
-
-	for rows.Next() {
-		var u models.User
 
-		// Prepare null values
-		var nullUpdated pq.NullTime
-
-		err := rows.Scan(
-			&u.Id,
-			&u.Username,
-			&u.Email,
-			&u.CreatedAt,
-			&nullUpdated,
-		)
-		if err != nil {
-			sendError(w, http.StatusBadRequest, err, "Could not start the transaction")
-			tx.Rollback()
-			return
-		}
-
-		u.UpdatedAt = nullUpdated.Time
-
-		usersArr = append(usersArr, u)
+	page := usersPage{
+		Data:       usersArr,
+		Pagination: pagination{Limit: limit},
+		Links:      links{Self: pageLink(r, r.URL.Query().Get("cursor"))},
 	}
 
-	// 5° Commit transaction
-	tx.Commit()
+	if len(usersArr) > limit {
+		page.Data = usersArr[:limit]
+		page.Pagination.HasMore = true
+		page.Pagination.NextCursor = repository.CursorOf(page.Data[limit-1]).Encode()
+		page.Links.Next = pageLink(r, page.Pagination.NextCursor)
+	}
 
-	// 6° Encode the usersArr in a json
-	json, _ := json.Marshal(usersArr)
+	// 3° Encode the page in a json
+	json, _ := json.Marshal(page)
 
-	// 7° Send response
+	// 4° Send response
 	handler.SendResponse(w, http.StatusOK, json, "")
 }
 
@@ -298,65 +292,54 @@ func ReadAll(w http.ResponseWriter, r *http.Request) {
 // The user should be authenticated so it must sent the jwt through the headers
 func ReadById(w http.ResponseWriter, r *http.Request) {
 	// 1° Get the id from request url
-	urlParam := r.URL.Query().Get("id") // Return a string... should convert it to int
-	id, err := strconv.Atoi(urlParam)   // Convert it to int
+	id, err := userID(r)
 	if err != nil {
-		sendError(w, http.StatusBadRequest, err, "Could not fetch the id from url params")
+		sendError(w, r, invalidID(err))
 		return
 	}
 
-	// 2° Create a used object where the fetched user will be stored
-	u := models.User{Id: int64(id)}
-
-	// We are going to create a var where store the updated field in case it's null
-	nullUpdateAt := pq.NullTime{}
-
-	// 3° Setup the query to fetch the user
-	q := `SELECT id, username, email, created_at, updated_at
-			FROM users WHERE id = $1`
-
-	// 4° Init the connection to the database and start a transaction
-	db := connection.NewPostgresClient()
-
-	tx, err := db.Begin()
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start transaction with database")
+	// Regular users can only read their own account
+	if !canAccessUser(r, id) {
+		sendError(w, r, errNotOwner)
 		return
 	}
 
-	// 5° Prepare the transaction for the query (return a statement)
-	stmt, err := tx.Prepare(q)
+	// 2° Fetch the user
+	u, err := repo.GetByID(r.Context(), id)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not prepare the query to fetch the user")
-		tx.Rollback()
+		sendError(w, r, err)
 		return
 	}
-	defer stmt.Close()
 
-	// 6° Execute the query and assign the returned values to "u" var
+	// 3° Send the response, the ETag is needed to update the user
+	json, _ := json.Marshal(u)
 
-	err = stmt.QueryRow(id).Scan(
-		&u.Id,
-		&u.Username,
-		&u.Email,
-		&u.CreatedAt,
-		&nullUpdateAt,
-	)
+	w.Header().Set("ETag", etag(u))
+	handler.SendResponse(w, http.StatusOK, json, "")
+}
 
-	u.UpdatedAt = nullUpdateAt.Time
+// Get the authenticated user
+//
+// The user is the owner of the jwt sent through the headers.
+func ReadMe(w http.ResponseWriter, r *http.Request) {
+	// 1° Get the id from the claim of the token
+	claim, ok := auth.ClaimFromContext(r.Context())
+	if !ok {
+		sendError(w, r, problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "Missing token"))
+		return
+	}
 
+	// 2° Fetch the user
+	u, err := repo.GetByID(r.Context(), claim.UserId())
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not fetch a user with sent id")
-		tx.Rollback()
+		sendError(w, r, err)
 		return
 	}
 
-	// 7° Commit the transaction
-	tx.Commit()
-
-	// 8° Send the response
+	// 3° Send the response, the ETag is needed to update the user
 	json, _ := json.Marshal(u)
 
+	w.Header().Set("ETag", etag(u))
 	handler.SendResponse(w, http.StatusOK, json, "")
 }
 
@@ -364,66 +347,51 @@ func ReadById(w http.ResponseWriter, r *http.Request) {
 //
 // The user should be authenticated so it must sent the jwt through the headers
 func UpdateById(w http.ResponseWriter, r *http.Request) {
-	// 1° Get the id from the request url and convert it to int
-	urlParam := r.URL.Query().Get("id") // Returns a string
-
-	id, err := strconv.Atoi(urlParam) // Convert to int
+	// 1° Get the id from the request url
+	id, err := userID(r)
 	if err != nil {
-		sendError(w, http.StatusBadRequest, err, "Could not fetch the id from url params")
+		sendError(w, r, invalidID(err))
 		return
 	}
 
-	// 2° Create a user object and assign it values from request body
-	u := models.User{Id: int64(id)}
-	nullUpdatedAt := pq.NullTime{}
-
-	err = json.NewDecoder(r.Body).Decode(&u)
-	if err != nil {
-		sendError(w, http.StatusBadRequest, err, "Could not fetch the id from url params")
+	// Regular users can only modify their own account
+	if !canAccessUser(r, id) {
+		sendError(w, r, errNotOwner)
 		return
 	}
 
-	// 3° Prepare de query to the database
-	q := `UPDATE users SET username = $2, updated_at = now() 
-	WHERE id = $1 
-	RETURNING email, created_at, updated_at`
+	// 2° Decode the fields to update from the request body, the username
+	// follows the same rules as on the sign up and on PATCH
+	body := models.User{}
 
-	// 4° Init the connection to the database and start a transaction
-	db := connection.NewPostgresClient()
-
-	tx, err := db.Begin()
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start the trasaction to the database")
+		sendError(w, r, invalidBody(err))
 		return
 	}
 
-	// 5° Prepare the transaction
-	stmt, err := tx.Prepare(q)
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not prepare the trasaction")
-		tx.Rollback()
+	if err := models.ValidateUsername(body.Username); err != nil {
+		sendError(w, r, validationFailed(err))
 		return
 	}
-	defer stmt.Close()
 
-	// 6° Execute the query
-	err = stmt.QueryRow(u.Id, u.Username).Scan(
-		&u.Email,
-		&u.CreatedAt,
-		&nullUpdatedAt,
-	)
+	// 3° Fetch the current user, only the username is updated
+	u, err := repo.GetByID(r.Context(), id)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not execute the statement")
-		tx.Rollback()
+		sendError(w, r, err)
 		return
 	}
 
-	u.UpdatedAt = nullUpdatedAt.Time
+	u.Username = body.Username
 
-	// 7° Commit the transaction
-	tx.Commit()
+	// 4° Store the changes
+	err = repo.Update(r.Context(), &u)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
 
-	// 8° Send response
+	// 5° Send response
 	json, _ := json.Marshal(u)
 
 	handler.SendResponse(w, http.StatusOK, []byte(json), "")
@@ -433,84 +401,53 @@ func UpdateById(w http.ResponseWriter, r *http.Request) {
 //
 // The user should be authenticated
 func DeleteById(w http.ResponseWriter, r *http.Request) {
-	// 1° Get the id from request url and convert it to int
-	urlParam := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(urlParam)
-	if err != nil {
-		sendError(w, http.StatusBadRequest, err, "Could not fetch the id from url params")
-		return
-	}
-
-	// We are going to create a user object to store the values from the deleted user so that
-	// The user can see watch he deleted
-	u := models.User{}
-
-	// In order to manage null values from updated_at
-	nullUpdatedAt := pq.NullTime{}
-
-	// 2° Set up the query
-	q := `DELETE FROM users 
-	WHERE id = $1 
-	RETURNING id, username, email, created_at, updated_at`
-
-	// 3° Open database connection and start transaction
-	db := connection.NewPostgresClient()
-
-	tx, err := db.Begin()
+	// 1° Get the id from request url
+	id, err := userID(r)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not start transaction to the database")
+		sendError(w, r, invalidID(err))
 		return
 	}
 
-	// 4° Prepare transaction (statement)
-	stmt, err := tx.Prepare(q)
-	defer stmt.Close()
-	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not prepare transaction")
-		tx.Rollback()
+	// Regular users can only delete their own account
+	if !canAccessUser(r, id) {
+		sendError(w, r, errNotOwner)
 		return
 	}
 
-	// 5° Execute query
-	err = stmt.QueryRow(id).Scan(
-		&u.Id,
-		&u.Username,
-		&u.Email,
-		&u.CreatedAt,
-		&nullUpdatedAt,
-	)
+	// 2° Delete the user, we get its values so that the user can see what he deleted
+	u, err := repo.Delete(r.Context(), id)
 	if err != nil {
-		sendError(w, http.StatusInternalServerError, err, "Could not execute query")
-		tx.Rollback()
+		sendError(w, r, err)
 		return
 	}
 
-	u.UpdatedAt = nullUpdatedAt.Time
-
-	// 6° Commit transaction
-	tx.Commit()
+	// 3° Send Response
+	message, _ := json.Marshal(deletedUserResponse{
+		Message:     "User deleted successfully",
+		DeletedUser: u,
+	})
 
-	// 7° Send Response
-	userJson, _ := json.Marshal(u)
+	handler.SendResponse(w, http.StatusOK, message, "")
+}
 
-	message := fmt.Sprintf(`{
-		"message": "User deleted successfully",
-		"deleted_user": %s
-	}`, userJson)
+// Returns the id of the user of the url, /api/v2/users/{id} or ?id= on the v1 routes
+func userID(r *http.Request) (int64, error) {
+	v := chi.URLParam(r, "id")
+	if v == "" {
+		v = r.URL.Query().Get("id")
+	}
 
-	handler.SendResponse(w, http.StatusOK, []byte(message), "")
+	return strconv.ParseInt(v, 10, 64)
 }
 
-func sendError(w http.ResponseWriter, status int, err error, message string) {
-	// Log the error
-	logger.Log().Infof(message, ": ", err)
-
-	// Set a json with the error message
-	data := fmt.Sprintf(`{
-	"message": "%s",
-	"error": "%s"
-}`, message, err)
+// Checks if the authenticated user (The claim stored on the context by the
+// AuthenticationMiddleware) can access the account of the user with the id.
+// Users can access their own account, admins can access every account.
+func canAccessUser(r *http.Request, id int64) bool {
+	claim, ok := auth.ClaimFromContext(r.Context())
+	if !ok {
+		return false
+	}
 
-	json := []byte(data)
-	handler.SendError(w, status, json)
+	return claim.UserId() == id || claim.CanManageUsers()
 }
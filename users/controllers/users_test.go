@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
+	"github.com/go-chi/chi"
+)
+
+// Sets up the controllers with in memory storage and an HS256 key
+func setupControllers(t *testing.T) {
+	logger.InitZapLogger()
+
+	dir := t.TempDir()
+	secret := []byte("a-secret-that-is-long-enough-for-hs256")
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.hmac"), secret, 0600); err != nil {
+		t.Fatalf("❌ Could not write the secret: %v", err)
+	}
+	if err := auth.LoadKeys(dir, "", ""); err != nil {
+		t.Fatalf("❌ Could not load the keys: %v", err)
+	}
+
+	auth.SetRefreshStore(auth.NewMemoryRefreshStore())
+	auth.SetResetStore(auth.NewMemoryResetStore())
+	auth.SetRevocationList(auth.NewRevocationList(auth.NewMemoryRevocationBackend()))
+	SetRepository(repository.NewMemoryRepository())
+
+	// The emails are sent before the response so they don't outlive the test
+	prev := runInBackground
+	runInBackground = func(f func()) { f() }
+	t.Cleanup(func() {
+		runInBackground = prev
+	})
+}
+
+// Sends the body to the handler and returns the recorded response
+func doRequest(h http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+// Verify that a registered user can log in.
+func TestSignUpAndSignIn(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Token") == "" {
+		t.Fatalf("❌ Could not register the user: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if w.Code != http.StatusOK || w.Header().Get("Token") == "" {
+		t.Fatalf("❌ Could not log in: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "wrong"}`)
+	if w.Header().Get("Token") != "" {
+		t.Errorf("❌ Logged in with a wrong password")
+	} else {
+		t.Log("✅ User registered and logged in successfully.")
+	}
+}
+
+// Verify that the bodies are valid json whatever the username.
+func TestSignInEscapesUsername(t *testing.T) {
+	setupControllers(t)
+
+	doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "ra\\\"miro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+
+	w := doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	body := struct {
+		Username string
+		JWT      string
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("❌ The body is not valid json: %v %s", err, w.Body.String())
+	}
+
+	if body.Username != `ra\"miro` || body.JWT != w.Header().Get("Token") {
+		t.Errorf("❌ Unexpected body: %s", w.Body.String())
+	} else {
+		t.Log("✅ Username escaped on the body.")
+	}
+}
+
+// Verify that the password is never sent back.
+func TestResponsesWithoutPassword(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if strings.Contains(w.Body.String(), "password\"") {
+		t.Errorf("❌ The sign up sent the password: %s", w.Body.String())
+	}
+
+	claim, _ := auth.ValidateToken(context.Background(), w.Header().Get("Token"))
+	r := httptest.NewRequest(http.MethodGet, "/api/v2/users/me", nil)
+	r = r.WithContext(auth.NewContext(r.Context(), claim))
+	w = httptest.NewRecorder()
+	ReadMe(w, r)
+
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "password\"") {
+		t.Errorf("❌ The user was sent with the password: %d %s", w.Code, w.Body.String())
+	} else {
+		t.Log("✅ Password not sent on the responses.")
+	}
+}
+
+// Verify that a user can not update the account of another user.
+func TestUpdateAnotherUser(t *testing.T) {
+	setupControllers(t)
+
+	doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	w := doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "other", "email": "other@example.com", "password": "Blue-Sky-77"}`)
+
+	claim, err := auth.ValidateToken(context.Background(), w.Header().Get("Token"))
+	if err != nil {
+		t.Fatalf("❌ Could not validate the token: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPut, "/api/v1/updatebyid?id=1", strings.NewReader(`{"username": "hacked"}`))
+	r = r.WithContext(auth.NewContext(r.Context(), claim))
+	rec := httptest.NewRecorder()
+	UpdateById(rec, r)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("❌ Expected 403 when updating another user, got %d", rec.Code)
+	} else {
+		t.Log("✅ Update of another user was rejected.")
+	}
+}
+
+// Verify that the username is validated before it's updated.
+func TestUpdateInvalidUsername(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	claim, err := auth.ValidateToken(context.Background(), w.Header().Get("Token"))
+	if err != nil {
+		t.Fatalf("❌ Could not validate the token: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodPut, "/api/v1/updatebyid?id=1", strings.NewReader(`{"username": ""}`))
+	r = r.WithContext(auth.NewContext(r.Context(), claim))
+	rec := httptest.NewRecorder()
+	UpdateById(rec, r)
+
+	if code := problemCode(rec); rec.Code != http.StatusBadRequest || code != problem.CodeValidationFailed {
+		t.Fatalf("❌ Expected 400 %s, got %d %s", problem.CodeValidationFailed, rec.Code, rec.Body.String())
+	}
+
+	u, _ := repo.GetByID(context.Background(), 1)
+	if u.Username != "ramiro" {
+		t.Errorf("❌ The invalid username was stored: %q", u.Username)
+	} else {
+		t.Log("✅ Invalid username rejected.")
+	}
+}
+
+// Verify that the errors of the repository are sent as problems with their status.
+func TestErrorStatus(t *testing.T) {
+	setupControllers(t)
+
+	body := `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`
+	doRequest(SignUp, http.MethodPost, "/api/v1/register", body)
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		status int
+		code   string
+	}{
+		{"duplicated user", doRequest(SignUp, http.MethodPost, "/api/v1/register", body), http.StatusConflict, problem.CodeUserAlreadyExists},
+		{"invalid body", doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": `), http.StatusBadRequest, problem.CodeInvalidBody},
+		{"invalid user", doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "x"}`), http.StatusBadRequest, problem.CodeValidationFailed},
+		{"weak password", doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "other", "email": "other@example.com", "password": "123456"}`), http.StatusBadRequest, problem.CodeValidationFailed},
+		{"invalid refresh token", doRequest(RefreshToken, http.MethodPost, "/api/v1/token/refresh", `{"refresh_token": "nope"}`), http.StatusUnauthorized, problem.CodeInvalidRefreshToken},
+	}
+
+	for _, tt := range tests {
+		p := problem.Problem{}
+		err := json.Unmarshal(tt.w.Body.Bytes(), &p)
+		if err != nil || tt.w.Code != tt.status || p.Code != tt.code {
+			t.Errorf("❌ %s: expected %d %s, got %d %s", tt.name, tt.status, tt.code, tt.w.Code, tt.w.Body.String())
+		}
+		if tt.w.Header().Get("Content-Type") != problem.ContentType {
+			t.Errorf("❌ %s: unexpected Content-Type %s", tt.name, tt.w.Header().Get("Content-Type"))
+		}
+	}
+
+	t.Log("✅ Errors sent with their status.")
+}
+
+// Verify that a wrong password and an unknown email can not be told apart.
+func TestSignInFailures(t *testing.T) {
+	setupControllers(t)
+
+	doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+
+	wrongPassword := doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "wrong"}`)
+	unknownEmail := doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "nobody@example.com", "password": "wrong"}`)
+
+	for _, w := range []*httptest.ResponseRecorder{wrongPassword, unknownEmail} {
+		p := problem.Problem{}
+		json.Unmarshal(w.Body.Bytes(), &p)
+
+		if w.Code != http.StatusUnauthorized || p.Code != problem.CodeInvalidCredentials || p.Detail != "Invalid email or password" {
+			t.Errorf("❌ Expected 401 invalid_credentials, got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	t.Log("✅ Login failures are uniform.")
+}
+
+// Verify that a user that does not exist returns 404.
+func TestReadMissingUser(t *testing.T) {
+	setupControllers(t)
+
+	claim := models.Claim{Role: models.RoleAdmin}
+	claim.Subject = "1"
+
+	for _, h := range []http.HandlerFunc{ReadById, DeleteById} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/readbyid?id=99", nil)
+		r = r.WithContext(auth.NewContext(r.Context(), claim))
+		w := httptest.NewRecorder()
+		h(w, r)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("❌ Expected 404, got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	t.Log("✅ Missing users return 404.")
+}
+
+// Verify that the tokens of a missing user can not be revoked.
+func TestRevokeMissingUserTokens(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(RevokeUserTokens, http.MethodPost, "/api/v1/admin/revoketokens?id=99", "")
+	if code := problemCode(w); w.Code != http.StatusNotFound || code != problem.CodeUserNotFound {
+		t.Errorf("❌ Expected 404 user_not_found, got %d %s", w.Code, code)
+	} else {
+		t.Log("✅ Missing users return 404 on the revocation.")
+	}
+}
+
+// Verify that the pages of users are linked by their cursor.
+func TestReadAllPages(t *testing.T) {
+	setupControllers(t)
+
+	for _, username := range []string{"ramiro", "other", "another"} {
+		doRequest(SignUp, http.MethodPost, "/api/v1/register", `{"username": "`+username+`", "email": "`+username+`@example.com", "password": "Blue-Sky-77"}`)
+	}
+
+	w := doRequest(ReadAll, http.MethodGet, "/api/v1/readall?limit=2&sort=-id", "")
+	first := usersPage{}
+	json.Unmarshal(w.Body.Bytes(), &first)
+
+	if w.Code != http.StatusOK || len(first.Data) != 2 || !first.Pagination.HasMore || first.Data[0].Id != 3 {
+		t.Fatalf("❌ Unexpected first page %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(ReadAll, http.MethodGet, first.Links.Next, "")
+	second := usersPage{}
+	json.Unmarshal(w.Body.Bytes(), &second)
+
+	if len(second.Data) != 1 || second.Pagination.HasMore || second.Links.Next != "" || second.Data[0].Id != 1 {
+		t.Errorf("❌ Unexpected second page %s", w.Body.String())
+	}
+
+	for _, query := range []string{"limit=0", "limit=101", "sort=password", "created_from=yesterday", "cursor=nope"} {
+		w = doRequest(ReadAll, http.MethodGet, "/api/v1/readall?"+query, "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("❌ Expected 400 for %s, got %d", query, w.Code)
+		}
+	}
+
+	t.Log("✅ Users paginated.")
+}
+
+// Verify that the v2 routes read the id from the path and /me returns the owner of the token.
+func TestUsersResource(t *testing.T) {
+	setupControllers(t)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if w.Header().Get("Location") != "/api/v2/users/1" {
+		t.Errorf("❌ Unexpected Location %s", w.Header().Get("Location"))
+	}
+
+	claim, err := auth.ValidateToken(context.Background(), w.Header().Get("Token"))
+	if err != nil {
+		t.Fatalf("❌ Could not validate the token: %v", err)
+	}
+
+	mux := chi.NewMux()
+	mux.Get("/api/v2/users/me", ReadMe)
+	mux.Get("/api/v2/users/{id}", ReadById)
+
+	for _, target := range []string{"/api/v2/users/me", "/api/v2/users/1"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r = r.WithContext(auth.NewContext(r.Context(), claim))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+
+		u := models.User{}
+		json.Unmarshal(rec.Body.Bytes(), &u)
+		if rec.Code != http.StatusOK || u.Id != 1 {
+			t.Errorf("❌ %s: expected the user 1, got %d %s", target, rec.Code, rec.Body.String())
+		}
+	}
+
+	t.Log("✅ Users resource is working properly.")
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/common/mail"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/common/ratelimit"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/RamiroCuenca/go-jwt-auth/users/repository"
+)
+
+// What the users can do until they verify their email
+const (
+	// Everything, the email is verified but nothing requires it.
+	VerificationOff = "off"
+	// They can log in, but their JWT only works on the routes that don't
+	// require a verified email (See VerifiedEmailRequired).
+	VerificationRestrict = "restrict"
+	// They can't log in.
+	VerificationBlock = "block"
+)
+
+var (
+	verificationPolicy = VerificationOff
+	// Url of the link sent to verify the emails, the token is added to its query
+	verificationURL = "http://localhost:8000/api/v1/verify-email"
+)
+
+// Sets what the users can do until they verify their email, and the public
+// url of the app that is used on the verification links.
+// It may be called from the main package at the start of the application.
+func SetEmailVerification(policy, publicURL string) {
+	verificationPolicy = policy
+	verificationURL = strings.TrimRight(publicURL, "/") + "/api/v1/verify-email"
+}
+
+// Returns true if the JWT of the users that did not verify their email must
+// be rejected on the routes that require a verified email.
+func VerifiedEmailRequired() bool {
+	return verificationPolicy != VerificationOff
+}
+
+// Limits of the verification emails that are sent again: emails sent to the
+// same address, and requests of the same client
+var (
+	verificationEmailLimiter = ratelimit.New(3, time.Hour)
+	verificationIPLimiter    = ratelimit.New(10, time.Minute*15)
+)
+
+// Returns the error sent when the user must verify the email first
+func emailNotVerified() error {
+	return problem.New(http.StatusForbidden, problem.CodeEmailNotVerified,
+		"The email is not verified, open the link that was sent to it")
+}
+
+// Verifies the email of a user
+//
+// It must recieve the token of the link sent to the email on the query
+// (?token=...). The JWT issued before keep saying that the email is not
+// verified, the client has to refresh it.
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	// 1° Check the token, it tells the user and the email that it verifies
+	userId, email, err := auth.ValidateVerificationToken(r.URL.Query().Get("token"))
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	// 2° The email must still be the one of the user
+	u, err := repo.GetByID(r.Context(), userId)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		sendError(w, r, auth.ErrVerificationTokenInvalid)
+		return
+	}
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	if u.Email != email {
+		sendError(w, r, auth.ErrVerificationTokenInvalid)
+		return
+	}
+
+	// 3° Mark the email as verified, the links can be opened more than once
+	if !u.EmailVerified {
+		err = repo.VerifyEmail(r.Context(), u.Id, email)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			// The email changed since it was fetched
+			sendError(w, r, auth.ErrVerificationTokenInvalid)
+			return
+		}
+		if err != nil {
+			sendError(w, r, err)
+			return
+		}
+
+		logger.FromContext(r.Context()).Infof("Email of user %d verified successfully! :)", u.Id)
+	}
+
+	handler.SendResponse(w, http.StatusOK, []byte(`{
+		"Message": "Email verified successfully"
+	}`), "")
+}
+
+// Sends the verification link again
+//
+// It must recieve the email, it does not need a JWT because the users can't
+// log in before they verify it when the policy is block. As with the password
+// reset, the response does not tell whether the email is registered.
+func ResendVerification(w http.ResponseWriter, r *http.Request) {
+	// 1° Decode the json received
+	type resendVerificationCMD struct {
+		Email string `json:"email"`
+	}
+
+	cmd := resendVerificationCMD{}
+
+	err := json.NewDecoder(r.Body).Decode(&cmd)
+	if err != nil {
+		sendError(w, r, invalidBody(err))
+		return
+	}
+
+	cmd.Email = strings.TrimSpace(cmd.Email)
+	if cmd.Email == "" {
+		sendError(w, r, validationFailed(errors.New("Email is required")))
+		return
+	}
+
+	// 2° Check the limits of the client and of the email
+	if !allow(w, r, verificationIPLimiter, "ip:"+clientIP(r)) ||
+		!allow(w, r, verificationEmailLimiter, "email:"+strings.ToLower(cmd.Email)) {
+		return
+	}
+
+	// 3° Send the link, only to the registered emails that are not verified
+	ctx := logger.NewContext(context.Background(), logger.FromContext(r.Context()))
+	runInBackground(func() {
+		u, err := repo.GetByEmail(ctx, cmd.Email)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			logger.FromContext(ctx).Info("Verification asked for an email that is not registered")
+			return
+		}
+		if err != nil {
+			logger.FromContext(ctx).Errorf("Could not fetch the user of the verification. Reason: %v", err)
+			return
+		}
+
+		if u.EmailVerified {
+			logger.FromContext(ctx).Infof("Verification asked for user %d, that is already verified", u.Id)
+			return
+		}
+
+		sendVerificationEmail(ctx, u)
+	})
+
+	// 4° Send response
+	handler.SendResponse(w, http.StatusAccepted, []byte(`{
+		"Message": "If the email is registered and not verified, a verification link was sent to it"
+	}`), "")
+}
+
+// Sends the link that verifies the current email of the user
+func sendVerificationEmail(ctx context.Context, u models.User) {
+	log := logger.FromContext(ctx)
+
+	token, expiresAt, err := auth.IssueVerificationToken(u)
+	if err != nil {
+		log.Errorf("Could not generate the verification token of user %d. Reason: %v", u.Id, err)
+		return
+	}
+
+	link := verificationURL + "?token=" + url.QueryEscape(token)
+
+	err = mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(`Hi %s,
+
+Open this link to verify your email:
+
+%s
+
+The link expires at %s. If you did not create an account, ignore this email.
+`, u.Username, link, expiresAt.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		log.Errorf("Could not send the verification link to user %d. Reason: %v", u.Id, err)
+		return
+	}
+
+	log.Infof("Verification link sent to user %d successfully! :)", u.Id)
+}
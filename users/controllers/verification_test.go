@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/auth"
+	"github.com/RamiroCuenca/go-jwt-auth/common/handler"
+	"github.com/RamiroCuenca/go-jwt-auth/common/problem"
+	"github.com/RamiroCuenca/go-jwt-auth/common/ratelimit"
+)
+
+// The query of the verification link
+var verificationLinkPattern = regexp.MustCompile(`\?token=\S+`)
+
+// Sets up the controllers with a fake sender and the verification policy
+func setupVerification(t *testing.T, policy string) *fakeSender {
+	sender := setupReset(t)
+	verificationEmailLimiter = ratelimit.New(3, time.Hour)
+	verificationIPLimiter = ratelimit.New(10, time.Minute*15)
+
+	SetEmailVerification(policy, "http://localhost:8000")
+	t.Cleanup(func() {
+		SetEmailVerification(VerificationOff, "http://localhost:8000")
+	})
+
+	return sender
+}
+
+// Returns the target of the last verification link that was sent
+func lastVerificationLink(t *testing.T, sender *fakeSender) string {
+	if len(sender.sent) == 0 {
+		t.Fatalf("❌ No verification link was sent")
+	}
+
+	query := verificationLinkPattern.FindString(sender.sent[len(sender.sent)-1].Body)
+	if query == "" {
+		t.Fatalf("❌ The email has no verification link: %s", sender.sent[len(sender.sent)-1].Body)
+	}
+
+	return "/api/v1/verify-email" + query
+}
+
+// Verify that the link sent on the sign up verifies the email.
+func TestVerifyEmail(t *testing.T) {
+	sender := setupVerification(t, VerificationRestrict)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	claim, err := auth.ValidateToken(context.Background(), w.Header().Get("Token"))
+	if err != nil || !claim.Unverified() {
+		t.Fatalf("❌ Expected the token of the new user to be unverified: %v", err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].To != "ramiro@example.com" {
+		t.Fatalf("❌ The verification link was not sent: %v", sender.sent)
+	}
+
+	w = doRequest(VerifyEmail, http.MethodGet, lastVerificationLink(t, sender), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("❌ Could not verify the email: %d %s", w.Code, w.Body.String())
+	}
+
+	u, _ := repo.GetByEmail(context.Background(), "ramiro@example.com")
+	if !u.EmailVerified {
+		t.Errorf("❌ The email was not marked as verified")
+	}
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	claim, err = auth.ValidateToken(context.Background(), w.Header().Get("Token"))
+	if err != nil || claim.Unverified() {
+		t.Errorf("❌ Expected the new token to be verified: %v", err)
+	}
+
+	// The link can be opened again
+	w = doRequest(VerifyEmail, http.MethodGet, lastVerificationLink(t, sender), "")
+	if w.Code != http.StatusOK {
+		t.Errorf("❌ Could not open the link twice: %d %s", w.Code, w.Body.String())
+	} else {
+		t.Log("✅ Email verified with the link sent on the sign up.")
+	}
+}
+
+// Verify that the link stops working when the email changes.
+func TestVerifyEmailChanged(t *testing.T) {
+	sender := setupVerification(t, VerificationRestrict)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	link := lastVerificationLink(t, sender)
+
+	w = doPatch(t, w.Header().Get("Token"), `{"email": "cuenca@example.com"}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("❌ Could not change the email: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(VerifyEmail, http.MethodGet, link, "")
+	if code := problemCode(w); w.Code != http.StatusBadRequest || code != problem.CodeInvalidVerificationToken {
+		t.Errorf("❌ Expected the link of the old email to be rejected, got %d %s", w.Code, code)
+	}
+
+	w = doRequest(VerifyEmail, http.MethodGet, "/api/v1/verify-email?token=not-a-token", "")
+	if code := problemCode(w); code != problem.CodeInvalidVerificationToken {
+		t.Errorf("❌ Expected an invalid token, got %d %s", w.Code, code)
+	} else {
+		t.Log("✅ Link of the old email rejected.")
+	}
+}
+
+// Verify that the users can't log in before verifying the email when the
+// policy is block.
+func TestVerificationBlock(t *testing.T) {
+	sender := setupVerification(t, VerificationBlock)
+
+	w := doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Token") != "" {
+		t.Fatalf("❌ Expected the user to be created without a token: %d %s", w.Code, w.Header().Get("Token"))
+	}
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if code := problemCode(w); w.Code != http.StatusForbidden || code != problem.CodeEmailNotVerified {
+		t.Errorf("❌ Expected the login to be rejected, got %d %s", w.Code, code)
+	}
+
+	// A wrong password does not tell that the email is not verified
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "wrong"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("❌ Expected a wrong password to be unauthorized, got %d", w.Code)
+	}
+
+	doRequest(VerifyEmail, http.MethodGet, lastVerificationLink(t, sender), "")
+
+	w = doRequest(SignIn, http.MethodPost, "/api/v1/login", `{"email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	if w.Code != http.StatusOK || w.Header().Get("Token") == "" {
+		t.Errorf("❌ Could not log in after verifying the email: %d %s", w.Code, w.Body.String())
+	} else {
+		t.Log("✅ Login blocked until the email is verified.")
+	}
+}
+
+// Verify that the link is sent again only to the users that are not verified,
+// with the same response for every email.
+func TestResendVerification(t *testing.T) {
+	sender := setupVerification(t, VerificationRestrict)
+
+	doRequest(SignUp, http.MethodPost, "/api/v2/users", `{"username": "ramiro", "email": "ramiro@example.com", "password": "Blue-Sky-77"}`)
+	sender.sent = nil
+
+	known := doRequest(ResendVerification, http.MethodPost, "/api/v1/verify-email/resend", `{"email": "ramiro@example.com"}`)
+	unknown := doRequest(ResendVerification, http.MethodPost, "/api/v1/verify-email/resend", `{"email": "nobody@example.com"}`)
+
+	if known.Code != http.StatusAccepted || known.Code != unknown.Code || known.Body.String() != unknown.Body.String() {
+		t.Errorf("❌ The responses are different: %d %s / %d %s", known.Code, known.Body.String(), unknown.Code, unknown.Body.String())
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("❌ Expected only one email, got %d", len(sender.sent))
+	}
+
+	doRequest(VerifyEmail, http.MethodGet, lastVerificationLink(t, sender), "")
+	sender.sent = nil
+
+	doRequest(ResendVerification, http.MethodPost, "/api/v1/verify-email/resend", `{"email": "ramiro@example.com"}`)
+	if len(sender.sent) != 0 {
+		t.Errorf("❌ The link was sent to a verified email")
+	} else {
+		t.Log("✅ Link sent again only to the emails that are not verified.")
+	}
+}
+
+// Verify that the clients behind a trusted proxy have their own limit.
+func TestResendVerificationBehindProxy(t *testing.T) {
+	setupVerification(t, VerificationRestrict)
+	verificationEmailLimiter = ratelimit.New(100, time.Hour)
+
+	proxies, _ := handler.ParseTrustedProxies([]string{"10.0.0.0/8"})
+	SetTrustedProxies(proxies)
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	resend := func(client string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/verify-email/resend", strings.NewReader(`{"email": "nobody@example.com"}`))
+		r.RemoteAddr = "10.0.0.1:4000"
+		r.Header.Set("X-Forwarded-For", client)
+		w := httptest.NewRecorder()
+		ResendVerification(w, r)
+		return w.Code
+	}
+
+	for i := 0; i < 10; i++ {
+		resend("198.51.100.1")
+	}
+
+	if code := resend("198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Errorf("❌ Expected the client to reach its limit, got %d", code)
+	}
+	if code := resend("198.51.100.2"); code != http.StatusAccepted {
+		t.Errorf("❌ Another client behind the proxy was limited: %d", code)
+	} else {
+		t.Log("✅ Each client behind the proxy has its own limit.")
+	}
+}
@@ -2,44 +2,161 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// Roles of the users
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
 type User struct {
-	Id        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Id          int64     `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Password    string    `json:"password,omitempty"` // Only on the requests, never sent back
+	Role        string    `json:"role"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// EmailVerified is true once the user opens the link sent to the email.
+	EmailVerified bool `json:"email_verified"`
+	// PasswordChangedAt is zero if the password was never changed.
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	// Version is increased on every update, it's the ETag of the user.
+	Version int64 `json:"version"`
 }
 
+// Maximum length of the profile fields
+const (
+	maxDisplayNameLength = 100
+	maxBioLength         = 500
+)
+
 func validate(u User) error {
+	if err := ValidateUsername(u.Username); err != nil {
+		return err
+	}
+	if err := ValidateEmail(u.Email); err != nil {
+		return err
+	}
+
+	// Check that the Password is as strong as the ones set on a change or a
+	// reset, so that the user could set it again.
+	if err := ValidatePasswordStrength(u.Password, u); err != nil {
+		return err
+	}
+
+	if err := ValidateDisplayName(u.DisplayName); err != nil {
+		return err
+	}
+
+	return ValidateBio(u.Bio)
+}
+
+// Checks the username of a new or updated user.
+func ValidateUsername(username string) error {
 	// Check that the Username is not empty.
-	if u.Username == "" {
+	if username == "" {
 		return errors.New("Username can not be empty")
 	}
 
 	// Check that the username has less than 51 digits.
-	if len(u.Username) > 50 {
+	if len(username) > 50 {
 		return errors.New("Username can not be larger than 50 digits")
 	}
+
+	return nil
+}
+
+// Checks the email of a new or updated user.
+func ValidateEmail(email string) error {
 	// Check that the Email is not empty.
-	if u.Email == "" {
+	if email == "" {
 		return errors.New("Email can not be empty")
 	}
 
 	// Check that the Email contains an @.
-	if !strings.Contains(u.Email, "@") {
+	if !strings.Contains(email, "@") {
 		return errors.New("Email must be valid (include @)")
 	}
 
-	// Check that the Password is longer than 6 digits.
-	if len(u.Password) < 6 {
-		return errors.New("Password must have at least 6 digits")
+	// Check that the Email is only an address, without a name nor spaces.
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return errors.New("Email must be valid")
+	}
+
+	// Check that the Email fits on the column.
+	if len(email) > 80 {
+		return errors.New("Email can not be larger than 80 digits")
+	}
+
+	return nil
+}
+
+// Minimum and maximum length of the new passwords, bcrypt ignores what goes
+// after the 72th byte
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// Checks that a new password is strong enough: at least 8 digits, with
+// letters and numbers, and not based on the username nor the email of the user.
+//
+// It's checked on the sign up and when the password is changed or reset, the
+// passwords of the accounts created before these rules are still valid.
+func ValidatePasswordStrength(password string, u User) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("Password must have at least %d digits", minPasswordLength)
+	}
+
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("Password can not be larger than %d bytes", maxPasswordLength)
+	}
+
+	hasLetter, hasNumber := false, false
+	for _, c := range password {
+		hasLetter = hasLetter || unicode.IsLetter(c)
+		hasNumber = hasNumber || unicode.IsDigit(c)
+	}
+	if !hasLetter || !hasNumber {
+		return errors.New("Password must have letters and numbers")
+	}
+
+	// The username and the email are the first thing an attacker tries
+	lower := strings.ToLower(password)
+	localPart := strings.Split(strings.ToLower(u.Email), "@")[0]
+	if (u.Username != "" && strings.Contains(lower, strings.ToLower(u.Username))) ||
+		(localPart != "" && strings.Contains(lower, localPart)) {
+		return errors.New("Password can not contain the username nor the email")
+	}
+
+	return nil
+}
+
+// Checks the display name, it's optional.
+func ValidateDisplayName(displayName string) error {
+	if len(displayName) > maxDisplayNameLength {
+		return fmt.Errorf("Display name can not be larger than %d digits", maxDisplayNameLength)
+	}
+
+	return nil
+}
+
+// Checks the bio, it's optional.
+func ValidateBio(bio string) error {
+	if len(bio) > maxBioLength {
+		return fmt.Errorf("Bio can not be larger than %d digits", maxBioLength)
 	}
 
 	return nil
@@ -53,7 +170,34 @@ func Check(u User) error {
 // In this case we are going to add only the username. The
 // other parameters are going to be automatically added by
 // the library that we are going to use.
+//
+// The id of the user travels as the subject (sub) of the token.
 type Claim struct {
 	Username string `json:"username"`
-	jwt.StandardClaims
+	Role     string `json:"role"`
+	// EmailVerified is nil on the tokens issued before the emails had to be
+	// verified, their accounts are considered verified.
+	EmailVerified *bool `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Returns the id of the user that owns the token (Parsed from the subject).
+// It returns 0 if the subject is not a valid id.
+func (c Claim) UserId() int64 {
+	id, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// Returns true if the token belongs to a user that has not verified the email.
+func (c Claim) Unverified() bool {
+	return c.EmailVerified != nil && !*c.EmailVerified
+}
+
+// Returns true if the user can read and modify the accounts of other users.
+func (c Claim) CanManageUsers() bool {
+	return c.Role == RoleAdmin
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -12,7 +13,7 @@ func TestUserWithCorrectParams(t *testing.T) {
 		Id:        12,
 		Username:  utils.GenerateRandomString(15),
 		Email:     utils.GenerateRandomString(10) + "@example.com",
-		Password:  utils.GenerateRandomString(15),
+		Password:  utils.GenerateRandomString(15) + "42",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -29,7 +30,7 @@ func TestUserWithEmptyUsername(t *testing.T) {
 		Id:        12,
 		Username:  "",
 		Email:     utils.GenerateRandomString(10) + "@example.com",
-		Password:  utils.GenerateRandomString(15),
+		Password:  utils.GenerateRandomString(15) + "42",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -46,7 +47,7 @@ func TestUserWithWrongUsername(t *testing.T) {
 		Id:        12,
 		Username:  utils.GenerateRandomString(53),
 		Email:     utils.GenerateRandomString(10) + "@example.com",
-		Password:  utils.GenerateRandomString(15),
+		Password:  utils.GenerateRandomString(15) + "42",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -63,7 +64,7 @@ func TestUserWithWrongEmail(t *testing.T) {
 		Id:        12,
 		Username:  utils.GenerateRandomString(15),
 		Email:     utils.GenerateRandomString(10) + "example.com", // It does not contain the @
-		Password:  utils.GenerateRandomString(15),
+		Password:  utils.GenerateRandomString(15) + "42",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -80,7 +81,7 @@ func TestUserWithWrongPassword(t *testing.T) {
 		Id:        12,
 		Username:  utils.GenerateRandomString(15),
 		Email:     utils.GenerateRandomString(10) + "@example.com",
-		Password:  utils.GenerateRandomString(5), // Less than 6 digits
+		Password:  utils.GenerateRandomString(5), // Less than 8 digits
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -91,3 +92,48 @@ func TestUserWithWrongPassword(t *testing.T) {
 		t.Log("✅ Stopped creation of the user with an invalid password.")
 	}
 }
+
+func TestClaimUserId(t *testing.T) {
+	c := Claim{}
+	c.Subject = "42"
+
+	if id := c.UserId(); id != 42 {
+		t.Errorf("❌ Expected the user id to be 42, got %d.", id)
+	} else {
+		t.Log("✅ User id fetched from the subject successfully.")
+	}
+}
+
+func TestClaimCanManageUsers(t *testing.T) {
+	admin := Claim{Role: RoleAdmin}
+	user := Claim{Role: RoleUser}
+
+	if !admin.CanManageUsers() || user.CanManageUsers() {
+		t.Errorf("❌ Only admins should be able to manage other users.")
+	} else {
+		t.Log("✅ Only admins can manage other users.")
+	}
+}
+
+func TestPasswordStrength(t *testing.T) {
+	u := User{Username: "ramiro", Email: "cuenca@example.com"}
+
+	tests := map[string]bool{
+		"Correct-Horse-42":       true,
+		"short1":                 false,
+		"onlyletters":            false,
+		"1234567890":             false,
+		"myRamiro2021":           false,
+		"cuenca12345":            false,
+		strings.Repeat("a1", 37): false,
+		"contraseña segura 2021": true,
+	}
+
+	for password, valid := range tests {
+		if err := ValidatePasswordStrength(password, u); (err == nil) != valid {
+			t.Errorf("❌ %q: expected valid %v, got %v", password, valid, err)
+		}
+	}
+
+	t.Log("✅ Password strength checked.")
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// Columns by which the users can be sorted
+const (
+	SortByID        = "id"
+	SortByCreatedAt = "created_at"
+)
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// ListOptions filters, sorts and paginates the list of users.
+//
+// The pages use keyset pagination: instead of an offset, the next page starts
+// after the last user of the previous one (The cursor). It's as fast for the
+// last page as for the first one and no user is skipped nor repeated when
+// users are created or deleted between the pages.
+type ListOptions struct {
+	// Prefixes of the username and the email, case insensitive.
+	UsernamePrefix string
+	EmailPrefix    string
+	// Users created in [CreatedFrom, CreatedTo), the zero time is no limit.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	// SortByID (Default) or SortByCreatedAt, the id breaks the ties.
+	SortBy string
+	Desc   bool
+
+	// Maximum number of users returned, 0 is no limit.
+	Limit int
+	// Only the users after the cursor are returned, nil for the first page.
+	After *Cursor
+}
+
+// Cursor is the position of a user on the list, the page that follows it
+// starts after it.
+type Cursor struct {
+	Id        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Returns the cursor that points to the user.
+func CursorOf(u models.User) *Cursor {
+	return &Cursor{Id: u.Id, CreatedAt: u.CreatedAt}
+}
+
+// Encodes the cursor as an opaque string that can be sent on an url.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decodes a cursor encoded with Encode, it returns ErrInvalidCursor if the
+// string was not returned by Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := Cursor{}
+	if err := json.Unmarshal(data, &c); err != nil || c.Id <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// Checks the options before they are used on a query
+func (o ListOptions) validate() error {
+	switch o.SortBy {
+	case "", SortByID, SortByCreatedAt:
+	default:
+		return fmt.Errorf("Can not sort by %s", o.SortBy)
+	}
+
+	if o.Limit < 0 {
+		return errors.New("The limit can not be negative")
+	}
+
+	return nil
+}
+
+// Builds the WHERE, ORDER BY and LIMIT of the list query, shared by the sql
+// repositories. placeholder returns the placeholder of the nth argument ($1
+// on postgres, ? on SQLite).
+func listQuery(o ListOptions, placeholder func(n int) string) (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	// Adds the argument and returns its placeholder
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return placeholder(len(args))
+	}
+
+	if o.UsernamePrefix != "" {
+		conditions = append(conditions, `lower(username) LIKE `+arg(likePrefix(o.UsernamePrefix))+` ESCAPE '\'`)
+	}
+	if o.EmailPrefix != "" {
+		conditions = append(conditions, `lower(email) LIKE `+arg(likePrefix(o.EmailPrefix))+` ESCAPE '\'`)
+	}
+	if !o.CreatedFrom.IsZero() {
+		conditions = append(conditions, `created_at >= `+arg(o.CreatedFrom.UTC()))
+	}
+	if !o.CreatedTo.IsZero() {
+		conditions = append(conditions, `created_at < `+arg(o.CreatedTo.UTC()))
+	}
+
+	// The users after the cursor, in the direction of the sort
+	operator, direction := ">", "ASC"
+	if o.Desc {
+		operator, direction = "<", "DESC"
+	}
+
+	order := "id " + direction
+	if o.SortBy == SortByCreatedAt {
+		order = "created_at " + direction + ", id " + direction
+
+		if o.After != nil {
+			conditions = append(conditions, fmt.Sprintf(
+				"(created_at %s %s OR (created_at = %s AND id %s %s))",
+				operator, arg(o.After.CreatedAt.UTC()), arg(o.After.CreatedAt.UTC()), operator, arg(o.After.Id),
+			))
+		}
+	} else if o.After != nil {
+		conditions = append(conditions, "id "+operator+" "+arg(o.After.Id))
+	}
+
+	q := ""
+	if len(conditions) > 0 {
+		q += "WHERE " + strings.Join(conditions, " AND ")
+	}
+	q += " ORDER BY " + order
+	if o.Limit > 0 {
+		q += " LIMIT " + arg(o.Limit)
+	}
+
+	return q, args
+}
+
+// Returns the LIKE pattern that matches the strings that start with prefix,
+// the wildcards of the prefix are escaped.
+func likePrefix(prefix string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(prefix))
+	return escaped + "%"
+}
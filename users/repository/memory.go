@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// In memory implementation of the UserRepository, the users are lost when
+// the app stops. It's meant for tests and local development.
+type memoryRepository struct {
+	mu     sync.RWMutex
+	users  map[int64]models.User
+	nextId int64
+}
+
+// Returns an empty in memory UserRepository.
+func NewMemoryRepository() UserRepository {
+	return &memoryRepository{users: map[int64]models.User{}}
+}
+
+func (m *memoryRepository) Create(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.isTaken(0, u.Username, u.Email) {
+		return ErrUserAlreadyExists
+	}
+
+	m.nextId++
+	u.Id = m.nextId
+	u.CreatedAt = time.Now()
+	u.Version = 1
+	m.users[u.Id] = *u
+
+	return nil
+}
+
+func (m *memoryRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return models.User{}, ErrUserNotFound
+}
+
+func (m *memoryRepository) GetByID(ctx context.Context, id int64) (models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+
+	return withoutPassword(u), nil
+}
+
+func (m *memoryRepository) List(ctx context.Context, opts ListOptions) ([]models.User, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	usersArr := make([]models.User, 0, len(m.users))
+	for _, u := range m.users {
+		if matches(u, opts) {
+			usersArr = append(usersArr, withoutPassword(u))
+		}
+	}
+
+	sort.Slice(usersArr, func(i, j int) bool {
+		return before(usersArr[i], usersArr[j], opts)
+	})
+
+	if opts.Limit > 0 && len(usersArr) > opts.Limit {
+		usersArr = usersArr[:opts.Limit]
+	}
+
+	return usersArr, nil
+}
+
+func (m *memoryRepository) Update(ctx context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[u.Id]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if u.Version != 0 && u.Version != stored.Version {
+		return ErrVersionMismatch
+	}
+
+	if m.isTaken(u.Id, u.Username, u.Email) {
+		return ErrUserAlreadyExists
+	}
+
+	// A new email has to be verified again
+	stored.EmailVerified = stored.EmailVerified && stored.Email == u.Email
+	stored.Username = u.Username
+	stored.Email = u.Email
+	stored.DisplayName = u.DisplayName
+	stored.Bio = u.Bio
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	m.users[u.Id] = stored
+
+	*u = withoutPassword(stored)
+
+	return nil
+}
+
+func (m *memoryRepository) GetPassword(ctx context.Context, id int64) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return "", ErrUserNotFound
+	}
+
+	return u.Password, nil
+}
+
+func (m *memoryRepository) UpdatePassword(ctx context.Context, id int64, hashedPassword string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	u.Password = hashedPassword
+	u.PasswordChangedAt = time.Now()
+	u.UpdatedAt = u.PasswordChangedAt
+	u.Version++
+	m.users[id] = u
+
+	return nil
+}
+
+func (m *memoryRepository) VerifyEmail(ctx context.Context, id int64, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok || u.Email != email {
+		return ErrUserNotFound
+	}
+
+	u.EmailVerified = true
+	u.UpdatedAt = time.Now()
+	u.Version++
+	m.users[id] = u
+
+	return nil
+}
+
+func (m *memoryRepository) Delete(ctx context.Context, id int64) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+
+	delete(m.users, id)
+
+	return withoutPassword(u), nil
+}
+
+// Checks if another user (Not the one with the id) has the username or email.
+// The caller must hold the lock.
+func (m *memoryRepository) isTaken(id int64, username, email string) bool {
+	for _, u := range m.users {
+		if u.Id != id && (u.Username == username || u.Email == email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks if the user passes the filters and is after the cursor
+func matches(u models.User, opts ListOptions) bool {
+	if !strings.HasPrefix(strings.ToLower(u.Username), strings.ToLower(opts.UsernamePrefix)) ||
+		!strings.HasPrefix(strings.ToLower(u.Email), strings.ToLower(opts.EmailPrefix)) {
+		return false
+	}
+
+	if (!opts.CreatedFrom.IsZero() && u.CreatedAt.Before(opts.CreatedFrom)) ||
+		(!opts.CreatedTo.IsZero() && !u.CreatedAt.Before(opts.CreatedTo)) {
+		return false
+	}
+
+	if opts.After != nil {
+		cursor := models.User{Id: opts.After.Id, CreatedAt: opts.After.CreatedAt}
+		return before(cursor, u, opts)
+	}
+
+	return true
+}
+
+// Checks if a goes before b on the list
+func before(a, b models.User, opts ListOptions) bool {
+	if opts.Desc {
+		a, b = b, a
+	}
+
+	if opts.SortBy == SortByCreatedAt && !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+
+	return a.Id < b.Id
+}
+
+func withoutPassword(u models.User) models.User {
+	u.Password = ""
+	return u
+}
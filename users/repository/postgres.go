@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	"github.com/lib/pq"
+)
+
+// Postgres implementation of the UserRepository
+type postgresRepository struct {
+	db *sql.DB
+}
+
+// Returns a UserRepository that persists the users on the users table of Postgres.
+func NewPostgresRepository(db *sql.DB) UserRepository {
+	return &postgresRepository{db}
+}
+
+func (p *postgresRepository) Create(ctx context.Context, u *models.User) error {
+	q := `
+	INSERT INTO users (username, email, hashed_password, role, display_name, bio, email_verified, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+	RETURNING id, created_at, version
+	`
+
+	// We will use QueryRow because the exec method returns two methods that are
+	// not compatible with psql!
+	err := p.db.QueryRowContext(
+		ctx,
+		q,
+		u.Username,
+		u.Email,
+		u.Password,
+		u.Role,
+		u.DisplayName,
+		u.Bio,
+		u.EmailVerified,
+	).Scan(
+		&u.Id,
+		&u.CreatedAt,
+		&u.Version,
+	)
+
+	return postgresError(err)
+}
+
+func (p *postgresRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	q := `
+	SELECT ` + userColumns + `, hashed_password
+	FROM users WHERE email = $1
+	`
+
+	password := ""
+
+	u, err := scanUser(p.db.QueryRowContext(ctx, q, email), &password)
+	if err != nil {
+		return models.User{}, postgresError(err)
+	}
+
+	u.Password = password
+
+	return u, nil
+}
+
+func (p *postgresRepository) GetByID(ctx context.Context, id int64) (models.User, error) {
+	q := `
+	SELECT ` + userColumns + `
+	FROM users WHERE id = $1
+	`
+
+	u, err := scanUser(p.db.QueryRowContext(ctx, q, id))
+	return u, postgresError(err)
+}
+
+func (p *postgresRepository) List(ctx context.Context, opts ListOptions) ([]models.User, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	where, args := listQuery(opts, func(n int) string { return "$" + strconv.Itoa(n) })
+
+	q := `
+	SELECT ` + userColumns + `
+	FROM users ` + where
+
+	rows, err := p.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usersArr := []models.User{}
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		usersArr = append(usersArr, u)
+	}
+
+	return usersArr, rows.Err()
+}
+
+func (p *postgresRepository) Update(ctx context.Context, u *models.User) error {
+	// The version is only checked if the caller knows it. The email stays
+	// verified only if it did not change (email is the old one on the SET)
+	q := `
+	UPDATE users SET username = $2, email = $3, display_name = $4, bio = $5,
+		email_verified = (email_verified AND email = $3),
+		updated_at = now(), version = version + 1
+	WHERE id = $1 AND ($6::BIGINT = 0 OR version = $6)
+	RETURNING ` + userColumns
+
+	updated, err := scanUser(p.db.QueryRowContext(ctx, q, u.Id, u.Username, u.Email, u.DisplayName, u.Bio, u.Version))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either the user does not exist or it has another version
+		exists := false
+		if err := p.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, u.Id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrVersionMismatch
+		}
+	}
+	if err != nil {
+		return postgresError(err)
+	}
+
+	*u = updated
+
+	return nil
+}
+
+func (p *postgresRepository) GetPassword(ctx context.Context, id int64) (string, error) {
+	q := `SELECT hashed_password FROM users WHERE id = $1`
+
+	password := ""
+	err := p.db.QueryRowContext(ctx, q, id).Scan(&password)
+
+	return password, postgresError(err)
+}
+
+func (p *postgresRepository) UpdatePassword(ctx context.Context, id int64, hashedPassword string) error {
+	q := `
+	UPDATE users SET hashed_password = $2, password_changed_at = now(),
+		updated_at = now(), version = version + 1
+	WHERE id = $1
+	`
+
+	result, err := p.db.ExecContext(ctx, q, id, hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	return rowsAffected(result)
+}
+
+func (p *postgresRepository) VerifyEmail(ctx context.Context, id int64, email string) error {
+	q := `
+	UPDATE users SET email_verified = TRUE, updated_at = now(), version = version + 1
+	WHERE id = $1 AND email = $2
+	`
+
+	result, err := p.db.ExecContext(ctx, q, id, email)
+	if err != nil {
+		return err
+	}
+
+	return rowsAffected(result)
+}
+
+func (p *postgresRepository) Delete(ctx context.Context, id int64) (models.User, error) {
+	q := `
+	DELETE FROM users WHERE id = $1
+	RETURNING ` + userColumns + `
+	`
+
+	u, err := scanUser(p.db.QueryRowContext(ctx, q, id))
+	return u, postgresError(err)
+}
+
+// Translates the errors of the driver into the errors of the repository
+func postgresError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+
+	// 23505 is the code of unique_violation
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrUserAlreadyExists
+	}
+
+	return err
+}
+
+// Returns ErrUserNotFound if the statement did not change any user
+func rowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Common interface of sql.Row and sql.Rows, shared by the sql repositories
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Columns of a user without the password, in the order of scanUser
+const userColumns = `id, username, email, role, display_name, bio, email_verified, created_at, updated_at, password_changed_at, version`
+
+// Scans the userColumns, followed by the extra columns
+func scanUser(row scanner, extra ...interface{}) (models.User, error) {
+	u := models.User{}
+
+	// In order to manage null values from updated_at and password_changed_at
+	nullUpdatedAt := sql.NullTime{}
+	nullPasswordChangedAt := sql.NullTime{}
+
+	dest := []interface{}{
+		&u.Id,
+		&u.Username,
+		&u.Email,
+		&u.Role,
+		&u.DisplayName,
+		&u.Bio,
+		&u.EmailVerified,
+		&u.CreatedAt,
+		&nullUpdatedAt,
+		&nullPasswordChangedAt,
+		&u.Version,
+	}
+
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	u.UpdatedAt = nullUpdatedAt.Time
+	u.PasswordChangedAt = nullPasswordChangedAt.Time
+
+	return u, nil
+}
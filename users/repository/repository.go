@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+var (
+	ErrUserNotFound      = errors.New("User not found")
+	ErrUserAlreadyExists = errors.New("There is already a user with that username or email")
+	ErrVersionMismatch   = errors.New("The user was modified by someone else, fetch it again")
+)
+
+// UserRepository is where the users are persisted.
+//
+// Every method receives the context of the request, so that the queries are
+// cancelled with it and traced as part of it.
+//
+// The methods return ErrUserNotFound if there is no user with the id or email
+// and ErrUserAlreadyExists if the username or email are already taken.
+//
+// Every user has a version that is increased on every update, so that two
+// concurrent updates can not overwrite each other (Optimistic concurrency).
+type UserRepository interface {
+	// Create stores a new user and assigns its id and creation date.
+	// The Password field must already be hashed.
+	Create(ctx context.Context, u *models.User) error
+	// GetByEmail returns the user including its hashed password (Used to log in).
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	// GetByID returns the user without its password.
+	GetByID(ctx context.Context, id int64) (models.User, error)
+	// List returns the users (Without passwords) that match the options, in
+	// their order (See ListOptions).
+	List(ctx context.Context, opts ListOptions) ([]models.User, error)
+	// Update stores the username, email and profile of the user, and
+	// assigns the update date and the new version. If u.Version is not 0 and
+	// the stored user has another version, it returns ErrVersionMismatch.
+	// A new email is not verified, whatever u.EmailVerified says.
+	Update(ctx context.Context, u *models.User) error
+	// GetPassword returns the hashed password of the user.
+	GetPassword(ctx context.Context, id int64) (string, error)
+	// UpdatePassword stores the new hashed password and the date of the
+	// change, and increases the version of the user.
+	UpdatePassword(ctx context.Context, id int64, hashedPassword string) error
+	// VerifyEmail marks the email of the user as verified, only if it's still
+	// the email of the user (ErrUserNotFound otherwise). It increases the
+	// version of the user.
+	VerifyEmail(ctx context.Context, id int64, email string) error
+	// Delete removes the user and returns it.
+	Delete(ctx context.Context, id int64) (models.User, error)
+}
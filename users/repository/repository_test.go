@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/logger"
+	"github.com/RamiroCuenca/go-jwt-auth/database/connection"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// Returns every implementation that can run without a server
+func testRepositories(t *testing.T) map[string]UserRepository {
+	logger.InitZapLogger()
+
+	db, err := connection.NewSQLiteClient(":memory:")
+	if err != nil {
+		t.Fatalf("❌ Could not open the SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	sqlite, err := NewSQLiteRepository(db)
+	if err != nil {
+		t.Fatalf("❌ Could not create the SQLite repository: %v", err)
+	}
+
+	return map[string]UserRepository{
+		"memory": NewMemoryRepository(),
+		"sqlite": sqlite,
+	}
+}
+
+// Verify the whole life of a user on every repository.
+func TestUserRepository(t *testing.T) {
+	ctx := context.Background()
+
+	for name, repo := range testRepositories(t) {
+		u := models.User{Username: "ramiro", Email: "ramiro@example.com", Password: "hash", Role: models.RoleUser}
+
+		if err := repo.Create(ctx, &u); err != nil || u.Id == 0 {
+			t.Fatalf("❌ [%s] Could not create the user: %v", name, err)
+		}
+
+		duplicated := models.User{Username: "ramiro", Email: "other@example.com", Password: "hash", Role: models.RoleUser}
+		if err := repo.Create(ctx, &duplicated); !errors.Is(err, ErrUserAlreadyExists) {
+			t.Errorf("❌ [%s] Expected a duplicated username error, got: %v", name, err)
+		}
+
+		byEmail, err := repo.GetByEmail(ctx, "ramiro@example.com")
+		if err != nil || byEmail.Id != u.Id || byEmail.Password != "hash" {
+			t.Errorf("❌ [%s] Could not fetch the user by email: %v", name, err)
+		}
+
+		byId, err := repo.GetByID(ctx, u.Id)
+		if err != nil || byId.Username != "ramiro" || byId.Password != "" {
+			t.Errorf("❌ [%s] Could not fetch the user by id: %v", name, err)
+		}
+
+		byId.Username = "cuenca"
+		if err := repo.Update(ctx, &byId); err != nil || byId.UpdatedAt.IsZero() {
+			t.Errorf("❌ [%s] Could not update the user: %v", name, err)
+		}
+
+		list, err := repo.List(ctx, ListOptions{})
+		if err != nil || len(list) != 1 || list[0].Username != "cuenca" {
+			t.Errorf("❌ [%s] Could not list the users: %v", name, err)
+		}
+
+		deleted, err := repo.Delete(ctx, u.Id)
+		if err != nil || deleted.Id != u.Id {
+			t.Errorf("❌ [%s] Could not delete the user: %v", name, err)
+		}
+
+		if _, err := repo.GetByID(ctx, u.Id); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("❌ [%s] Expected a not found error, got: %v", name, err)
+		} else {
+			t.Logf("✅ [%s] Repository is working properly.", name)
+		}
+	}
+}
+
+// Verify the filters and that the pages of every sort cover every user once.
+func TestListPagination(t *testing.T) {
+	ctx := context.Background()
+
+	for name, repo := range testRepositories(t) {
+		for _, username := range []string{"alice", "bob", "carol", "Bobby", "dave"} {
+			u := models.User{Username: username, Email: username + "@example.com", Password: "hash", Role: models.RoleUser}
+			if err := repo.Create(ctx, &u); err != nil {
+				t.Fatalf("❌ [%s] Could not create the user: %v", name, err)
+			}
+		}
+
+		for _, opts := range []ListOptions{
+			{SortBy: SortByID},
+			{SortBy: SortByID, Desc: true},
+			{SortBy: SortByCreatedAt},
+			{SortBy: SortByCreatedAt, Desc: true},
+		} {
+			ids := []int64{}
+			opts.Limit = 2
+
+			for page := 0; page < 5; page++ {
+				users, err := repo.List(ctx, opts)
+				if err != nil {
+					t.Fatalf("❌ [%s] Could not list the users: %v", name, err)
+				}
+				for _, u := range users {
+					ids = append(ids, u.Id)
+				}
+				if len(users) < opts.Limit {
+					break
+				}
+				opts.After = CursorOf(users[len(users)-1])
+			}
+
+			// The users were created in the order of their ids
+			expected := []int64{1, 2, 3, 4, 5}
+			if opts.Desc {
+				expected = []int64{5, 4, 3, 2, 1}
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(expected) {
+				t.Errorf("❌ [%s] Sorted by %s (desc %v) expected %v, got %v", name, opts.SortBy, opts.Desc, expected, ids)
+			}
+		}
+
+		filters := map[string]struct {
+			opts     ListOptions
+			expected int
+		}{
+			"username prefix":  {ListOptions{UsernamePrefix: "BOB"}, 2},
+			"email prefix":     {ListOptions{EmailPrefix: "carol@"}, 1},
+			"escaped wildcard": {ListOptions{UsernamePrefix: "b_b"}, 0},
+			"created from":     {ListOptions{CreatedFrom: time.Now().Add(time.Hour)}, 0},
+			"created to":       {ListOptions{CreatedTo: time.Now().Add(time.Hour)}, 5},
+		}
+		for filter, tt := range filters {
+			users, err := repo.List(ctx, tt.opts)
+			if err != nil || len(users) != tt.expected {
+				t.Errorf("❌ [%s] Filter by %s expected %d users, got %d (%v)", name, filter, tt.expected, len(users), err)
+			}
+		}
+
+		t.Logf("✅ [%s] Users paginated and filtered.", name)
+	}
+}
+
+// Verify that a cursor survives the trip through the url.
+func TestCursor(t *testing.T) {
+	c := Cursor{Id: 7, CreatedAt: time.Date(2021, 10, 1, 12, 30, 0, 123456000, time.UTC)}
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil || decoded.Id != c.Id || !decoded.CreatedAt.Equal(c.CreatedAt) {
+		t.Errorf("❌ Expected %+v, got %+v %v", c, decoded, err)
+	}
+
+	for _, invalid := range []string{"", "not-base64!", "e30"} {
+		if _, err := DecodeCursor(invalid); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("❌ The cursor %q was accepted", invalid)
+		}
+	}
+
+	t.Log("✅ Cursor encoded and decoded.")
+}
+
+// Verify that an update of an old version of the user is rejected.
+func TestUpdateVersion(t *testing.T) {
+	ctx := context.Background()
+
+	for name, repo := range testRepositories(t) {
+		u := models.User{Username: "ramiro", Email: "ramiro@example.com", Password: "hash", Role: models.RoleUser}
+		if err := repo.Create(ctx, &u); err != nil || u.Version != 1 {
+			t.Fatalf("❌ [%s] Could not create the user: %v (Version %d)", name, err, u.Version)
+		}
+
+		first, second := u, u
+		first.Bio = "First"
+		if err := repo.Update(ctx, &first); err != nil || first.Version != 2 || first.Bio != "First" {
+			t.Errorf("❌ [%s] Could not update the user: %v (Version %d)", name, err, first.Version)
+		}
+
+		second.Bio = "Second"
+		if err := repo.Update(ctx, &second); !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("❌ [%s] Expected a version mismatch, got %v", name, err)
+		}
+
+		// Version 0 skips the check
+		second.Version = 0
+		if err := repo.Update(ctx, &second); err != nil || second.Version != 3 {
+			t.Errorf("❌ [%s] Could not update without version: %v", name, err)
+		}
+
+		missing := models.User{Id: 99, Username: "nobody", Email: "nobody@example.com", Version: 1}
+		if err := repo.Update(ctx, &missing); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("❌ [%s] Expected a not found error, got %v", name, err)
+		} else {
+			t.Logf("✅ [%s] Stale updates rejected.", name)
+		}
+	}
+}
+
+// Verify that the columns added after the first schema are added to an old SQLite database.
+func TestSQLiteUpgrade(t *testing.T) {
+	logger.InitZapLogger()
+
+	db, err := connection.NewSQLiteClient(":memory:")
+	if err != nil {
+		t.Fatalf("❌ Could not open the SQLite database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username VARCHAR(50) NOT NULL UNIQUE,
+		email VARCHAR(80) NOT NULL UNIQUE,
+		hashed_password VARCHAR(255) NOT NULL,
+		role VARCHAR(20) NOT NULL DEFAULT 'user',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME
+	)`)
+	if err != nil {
+		t.Fatalf("❌ Could not create the old schema: %v", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO users (username, email, hashed_password, created_at) VALUES ('old', 'old@example.com', 'hash', '2021-10-01')`)
+	if err != nil {
+		t.Fatalf("❌ Could not create the old user: %v", err)
+	}
+
+	repo, err := NewSQLiteRepository(db)
+	if err != nil {
+		t.Fatalf("❌ Could not upgrade the schema: %v", err)
+	}
+
+	u := models.User{Username: "ramiro", Email: "ramiro@example.com", Password: "hash", Role: models.RoleUser, Bio: "Gopher"}
+	if err := repo.Create(context.Background(), &u); err != nil || u.Version != 1 {
+		t.Errorf("❌ Could not create a user on the upgraded schema: %v", err)
+	}
+
+	// The users created before the verification are verified, the new ones are not
+	old, _ := repo.GetByID(context.Background(), 1)
+	created, _ := repo.GetByID(context.Background(), u.Id)
+	if !old.EmailVerified || created.EmailVerified {
+		t.Errorf("❌ Expected only the old user to be verified, got %v and %v", old.EmailVerified, created.EmailVerified)
+	} else {
+		t.Log("✅ SQLite schema upgraded.")
+	}
+}
+
+// Verify that the password is replaced and the date of the change stored.
+func TestUpdatePassword(t *testing.T) {
+	ctx := context.Background()
+
+	for name, repo := range testRepositories(t) {
+		u := models.User{Username: "ramiro", Email: "ramiro@example.com", Password: "hash", Role: models.RoleUser}
+		if err := repo.Create(ctx, &u); err != nil {
+			t.Fatalf("❌ [%s] Could not create the user: %v", name, err)
+		}
+
+		if err := repo.UpdatePassword(ctx, u.Id, "new-hash"); err != nil {
+			t.Errorf("❌ [%s] Could not update the password: %v", name, err)
+		}
+
+		password, err := repo.GetPassword(ctx, u.Id)
+		if err != nil || password != "new-hash" {
+			t.Errorf("❌ [%s] Expected the new hash, got %s %v", name, password, err)
+		}
+
+		updated, _ := repo.GetByID(ctx, u.Id)
+		if updated.PasswordChangedAt.IsZero() || updated.Version != 2 {
+			t.Errorf("❌ [%s] The change was not recorded: %+v", name, updated)
+		}
+
+		if err := repo.UpdatePassword(ctx, 99, "hash"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("❌ [%s] Expected a not found error, got %v", name, err)
+		} else {
+			t.Logf("✅ [%s] Password updated.", name)
+		}
+	}
+}
+
+// Verify that the email is verified only while it does not change.
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+
+	for name, repo := range testRepositories(t) {
+		u := models.User{Username: "ramiro", Email: "ramiro@example.com", Password: "hash", Role: models.RoleUser}
+		if err := repo.Create(ctx, &u); err != nil {
+			t.Fatalf("❌ [%s] Could not create the user: %v", name, err)
+		}
+
+		if err := repo.VerifyEmail(ctx, u.Id, "other@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("❌ [%s] Verified an email that is not the one of the user: %v", name, err)
+		}
+
+		if err := repo.VerifyEmail(ctx, u.Id, "ramiro@example.com"); err != nil {
+			t.Errorf("❌ [%s] Could not verify the email: %v", name, err)
+		}
+
+		verified, _ := repo.GetByID(ctx, u.Id)
+		if !verified.EmailVerified || verified.Version != 2 {
+			t.Errorf("❌ [%s] The email was not verified: %+v", name, verified)
+		}
+
+		// The same email stays verified, a new one does not
+		verified.Bio = "Gopher"
+		repo.Update(ctx, &verified)
+		if !verified.EmailVerified {
+			t.Errorf("❌ [%s] The email is not verified after updating the bio", name)
+		}
+
+		verified.Email = "new@example.com"
+		repo.Update(ctx, &verified)
+		if verified.EmailVerified {
+			t.Errorf("❌ [%s] The new email is verified", name)
+		} else {
+			t.Logf("✅ [%s] Email verified.", name)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+)
+
+// The schema of the users table for SQLite, it's created when the repository
+// is initialized because there are no migrations for SQLite
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username VARCHAR(50) NOT NULL UNIQUE,
+	email VARCHAR(80) NOT NULL UNIQUE,
+	hashed_password VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL DEFAULT 'user',
+	display_name VARCHAR(100) NOT NULL DEFAULT '',
+	bio VARCHAR(500) NOT NULL DEFAULT '',
+	email_verified BOOLEAN NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME,
+	password_changed_at DATETIME,
+	version INTEGER NOT NULL DEFAULT 1
+)`
+
+// Columns added after the first version of the schema, they are added to the
+// databases created before them
+var sqliteColumns = []struct{ name, definition string }{
+	{"display_name", "VARCHAR(100) NOT NULL DEFAULT ''"},
+	{"bio", "VARCHAR(500) NOT NULL DEFAULT ''"},
+	{"version", "INTEGER NOT NULL DEFAULT 1"},
+	{"password_changed_at", "DATETIME"},
+	// The users created before the verification keep working, the new ones
+	// always set the column
+	{"email_verified", "BOOLEAN NOT NULL DEFAULT 1"},
+}
+
+// SQLite implementation of the UserRepository
+type sqliteRepository struct {
+	db *sql.DB
+}
+
+// Returns a UserRepository that persists the users on an embedded SQLite
+// database. It creates the users table if it does not exist.
+func NewSQLiteRepository(db *sql.DB) (UserRepository, error) {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, err
+	}
+
+	if err := addSQLiteColumns(db); err != nil {
+		return nil, err
+	}
+
+	return &sqliteRepository{db}, nil
+}
+
+// Adds the columns of sqliteColumns that the users table does not have
+func addSQLiteColumns(db *sql.DB) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('users')`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := map[string]bool{}
+	for rows.Next() {
+		name := ""
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, column := range sqliteColumns {
+		if existing[column.name] {
+			continue
+		}
+
+		if _, err := db.Exec(`ALTER TABLE users ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteRepository) Create(ctx context.Context, u *models.User) error {
+	q := `
+	INSERT INTO users (username, email, hashed_password, role, display_name, bio, email_verified, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id, created_at, version
+	`
+
+	err := s.db.QueryRowContext(
+		ctx,
+		q,
+		u.Username,
+		u.Email,
+		u.Password,
+		u.Role,
+		u.DisplayName,
+		u.Bio,
+		u.EmailVerified,
+		time.Now().UTC(),
+	).Scan(
+		&u.Id,
+		&u.CreatedAt,
+		&u.Version,
+	)
+
+	return sqliteError(err)
+}
+
+func (s *sqliteRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	q := `
+	SELECT ` + userColumns + `, hashed_password
+	FROM users WHERE email = ?
+	`
+
+	password := ""
+
+	u, err := scanUser(s.db.QueryRowContext(ctx, q, email), &password)
+	if err != nil {
+		return models.User{}, sqliteError(err)
+	}
+
+	u.Password = password
+
+	return u, nil
+}
+
+func (s *sqliteRepository) GetByID(ctx context.Context, id int64) (models.User, error) {
+	q := `
+	SELECT ` + userColumns + `
+	FROM users WHERE id = ?
+	`
+
+	u, err := scanUser(s.db.QueryRowContext(ctx, q, id))
+	return u, sqliteError(err)
+}
+
+func (s *sqliteRepository) List(ctx context.Context, opts ListOptions) ([]models.User, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	where, args := listQuery(opts, func(int) string { return "?" })
+
+	q := `
+	SELECT ` + userColumns + `
+	FROM users ` + where
+
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usersArr := []models.User{}
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		usersArr = append(usersArr, u)
+	}
+
+	return usersArr, rows.Err()
+}
+
+func (s *sqliteRepository) Update(ctx context.Context, u *models.User) error {
+	// The version is only checked if the caller knows it. The email stays
+	// verified only if it did not change (email is the old one on the SET)
+	q := `
+	UPDATE users SET username = ?, email = ?, display_name = ?, bio = ?,
+		email_verified = (email_verified AND email = ?),
+		updated_at = ?, version = version + 1
+	WHERE id = ? AND (? = 0 OR version = ?)
+	RETURNING ` + userColumns
+
+	updated, err := scanUser(s.db.QueryRowContext(ctx, q,
+		u.Username, u.Email, u.DisplayName, u.Bio, u.Email, time.Now().UTC(), u.Id, u.Version, u.Version,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either the user does not exist or it has another version
+		exists := false
+		if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, u.Id).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrVersionMismatch
+		}
+	}
+	if err != nil {
+		return sqliteError(err)
+	}
+
+	*u = updated
+
+	return nil
+}
+
+func (s *sqliteRepository) GetPassword(ctx context.Context, id int64) (string, error) {
+	q := `SELECT hashed_password FROM users WHERE id = ?`
+
+	password := ""
+	err := s.db.QueryRowContext(ctx, q, id).Scan(&password)
+
+	return password, sqliteError(err)
+}
+
+func (s *sqliteRepository) UpdatePassword(ctx context.Context, id int64, hashedPassword string) error {
+	q := `
+	UPDATE users SET hashed_password = ?, password_changed_at = ?,
+		updated_at = ?, version = version + 1
+	WHERE id = ?
+	`
+
+	now := time.Now().UTC()
+
+	result, err := s.db.ExecContext(ctx, q, hashedPassword, now, now, id)
+	if err != nil {
+		return err
+	}
+
+	return rowsAffected(result)
+}
+
+func (s *sqliteRepository) VerifyEmail(ctx context.Context, id int64, email string) error {
+	q := `
+	UPDATE users SET email_verified = 1, updated_at = ?, version = version + 1
+	WHERE id = ? AND email = ?
+	`
+
+	result, err := s.db.ExecContext(ctx, q, time.Now().UTC(), id, email)
+	if err != nil {
+		return err
+	}
+
+	return rowsAffected(result)
+}
+
+func (s *sqliteRepository) Delete(ctx context.Context, id int64) (models.User, error) {
+	q := `
+	DELETE FROM users WHERE id = ?
+	RETURNING ` + userColumns + `
+	`
+
+	u, err := scanUser(s.db.QueryRowContext(ctx, q, id))
+	return u, sqliteError(err)
+}
+
+// Translates the errors of the driver into the errors of the repository
+func sqliteError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrUserNotFound
+	}
+
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrUserAlreadyExists
+	}
+
+	return err
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/RamiroCuenca/go-jwt-auth/common/tracing"
+	"github.com/RamiroCuenca/go-jwt-auth/users/models"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Wraps a UserRepository so that every query has its own span
+type tracedRepository struct {
+	repo   UserRepository
+	system string
+}
+
+// Returns the repository with a span around every method, system is the
+// database behind it (postgresql, sqlite or memory).
+func WithTracing(repo UserRepository, system string) UserRepository {
+	return &tracedRepository{repo, system}
+}
+
+// Starts the span of the method
+func (t *tracedRepository) start(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "UserRepository."+method,
+		semconv.DBSystemKey.String(t.system),
+		semconv.DBSQLTableKey.String("users"),
+		semconv.DBOperationKey.String(method),
+	)
+}
+
+func (t *tracedRepository) Create(ctx context.Context, u *models.User) error {
+	ctx, span := t.start(ctx, "Create")
+	defer span.End()
+
+	err := t.repo.Create(ctx, u)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	ctx, span := t.start(ctx, "GetByEmail")
+	defer span.End()
+
+	u, err := t.repo.GetByEmail(ctx, email)
+	tracing.RecordError(span, err)
+	return u, err
+}
+
+func (t *tracedRepository) GetByID(ctx context.Context, id int64) (models.User, error) {
+	ctx, span := t.start(ctx, "GetByID")
+	defer span.End()
+
+	u, err := t.repo.GetByID(ctx, id)
+	tracing.RecordError(span, err)
+	return u, err
+}
+
+func (t *tracedRepository) List(ctx context.Context, opts ListOptions) ([]models.User, error) {
+	ctx, span := t.start(ctx, "List")
+	defer span.End()
+
+	usersArr, err := t.repo.List(ctx, opts)
+	tracing.RecordError(span, err)
+	return usersArr, err
+}
+
+func (t *tracedRepository) Update(ctx context.Context, u *models.User) error {
+	ctx, span := t.start(ctx, "Update")
+	defer span.End()
+
+	err := t.repo.Update(ctx, u)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (t *tracedRepository) GetPassword(ctx context.Context, id int64) (string, error) {
+	ctx, span := t.start(ctx, "GetPassword")
+	defer span.End()
+
+	password, err := t.repo.GetPassword(ctx, id)
+	tracing.RecordError(span, err)
+	return password, err
+}
+
+func (t *tracedRepository) UpdatePassword(ctx context.Context, id int64, hashedPassword string) error {
+	ctx, span := t.start(ctx, "UpdatePassword")
+	defer span.End()
+
+	err := t.repo.UpdatePassword(ctx, id, hashedPassword)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (t *tracedRepository) VerifyEmail(ctx context.Context, id int64, email string) error {
+	ctx, span := t.start(ctx, "VerifyEmail")
+	defer span.End()
+
+	err := t.repo.VerifyEmail(ctx, id, email)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (t *tracedRepository) Delete(ctx context.Context, id int64) (models.User, error) {
+	ctx, span := t.start(ctx, "Delete")
+	defer span.End()
+
+	u, err := t.repo.Delete(ctx, id)
+	tracing.RecordError(span, err)
+	return u, err
+}
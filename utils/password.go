@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"time"
 
+	"github.com/RamiroCuenca/go-jwt-auth/common/metrics"
+	"github.com/RamiroCuenca/go-jwt-auth/common/tracing"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -10,18 +14,43 @@ import (
 // hashes it. The it return the generated hash.
 //
 // It uses the bcrypt library
-func PasswordHash(password string) (string, error) {
+func PasswordHash(ctx context.Context, password string) (string, error) {
+	defer metrics.ObservePassword("hash", time.Now())
+
+	_, span := tracing.Start(ctx, "PasswordHash")
+	defer span.End()
+
 	// Generates the hash
 	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		// return "", errors.New("Failed to generate hashed password: %v", err)
-		return "", fmt.Errorf("Failed to generate hashed password: %v", err)
+		err = fmt.Errorf("Failed to generate hashed password: %v", err)
+		tracing.RecordError(span, err)
+		return "", err
 	}
 
 	return string(hashedPass), nil
 }
 
 // Checks if the provided password is correct or not
-func PasswordCheck(password, hashedPassword string) error {
+func PasswordCheck(ctx context.Context, password, hashedPassword string) error {
+	defer metrics.ObservePassword("check", time.Now())
+
+	// A wrong password is not an error of the app, so the span is not failed
+	_, span := tracing.Start(ctx, "PasswordCheck")
+	defer span.End()
+
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
+
+// Hash of a password nobody knows, generated with bcrypt.DefaultCost so that
+// comparing against it takes as long as comparing against a real hash.
+const dummyHash = "$2a$10$Q/836xq70S0RNonzOR6YXeuZtFwNGe.O2LYllZBb88ynxqmdNlc0a"
+
+// Takes the same time as PasswordCheck and always fails.
+//
+// It must be called when the user does not exist, so that the response time of
+// the login does not tell whether an email is registered or not.
+func PasswordCheckDummy(ctx context.Context, password string) {
+	PasswordCheck(ctx, password, dummyHash)
+}
@@ -1,13 +1,18 @@
 package utils
 
-import "testing"
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 // Verify that PasswordHasher is hashing properly.
 func TestPasswordHasher(t *testing.T) {
 	p := "pass123"
 
 	// Generate the hashed password
-	hashedPassword, err := PasswordHash(p)
+	hashedPassword, err := PasswordHash(context.Background(), p)
 	if err != nil {
 		t.Errorf("❌ There was an error hashing the password: %v", err)
 	}
@@ -23,7 +28,7 @@ func TestPasswordVerifierWithCorrectPassword(t *testing.T) {
 	p := "pass123"
 
 	// Generate the hashed password
-	hashedPassword, err := PasswordHash(p)
+	hashedPassword, err := PasswordHash(context.Background(), p)
 	if err != nil {
 		t.Errorf("❌ There was an error hashing the password: %v", err)
 	}
@@ -34,7 +39,7 @@ func TestPasswordVerifierWithCorrectPassword(t *testing.T) {
 	}
 
 	// Check the password
-	err = PasswordCheck(p, hashedPassword)
+	err = PasswordCheck(context.Background(), p, hashedPassword)
 	if err != nil {
 		t.Errorf("❌ The password check failed: %v", err)
 	} else {
@@ -47,7 +52,7 @@ func TestPasswordVerifierWithWrongPassword(t *testing.T) {
 	p := "pass123"
 
 	// Generate the hashed password
-	hashedPassword, err := PasswordHash(p)
+	hashedPassword, err := PasswordHash(context.Background(), p)
 	if err != nil {
 		t.Errorf("❌ There was an error hashing the password: %v", err)
 	}
@@ -59,7 +64,7 @@ func TestPasswordVerifierWithWrongPassword(t *testing.T) {
 
 	// Check an incorrect password
 	incorrectPassword := "pass12345"
-	err = PasswordCheck(incorrectPassword, hashedPassword)
+	err = PasswordCheck(context.Background(), incorrectPassword, hashedPassword)
 	if err == nil {
 		t.Errorf("❌ The password check failed: %v", err)
 	} else {
@@ -72,7 +77,7 @@ func TestPasswordHasherWithTwoDifferentPasswords(t *testing.T) {
 	p := "pass123"
 
 	// Generate the hashed password
-	hashedPassword, err := PasswordHash(p)
+	hashedPassword, err := PasswordHash(context.Background(), p)
 	if err != nil {
 		t.Errorf("❌ There was an error hashing the password: %v", err)
 	}
@@ -83,7 +88,7 @@ func TestPasswordHasherWithTwoDifferentPasswords(t *testing.T) {
 	}
 
 	p2 := "pass12345"
-	hashedPassword2, err := PasswordHash(p2)
+	hashedPassword2, err := PasswordHash(context.Background(), p2)
 	if err != nil {
 		t.Errorf("❌ There was an error hashing the password: %v", err)
 	}
@@ -99,3 +104,20 @@ func TestPasswordHasherWithTwoDifferentPasswords(t *testing.T) {
 		t.Log("✅ PasswordHasher is working properly")
 	}
 }
+
+// Verify that the dummy check always fails and costs as much as a real one.
+func TestPasswordCheckDummy(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyHash))
+	if err != nil || cost != bcrypt.DefaultCost {
+		t.Errorf("❌ The dummy hash must use the default cost, got %d %v", cost, err)
+	}
+
+	// Nobody knows the password of the hash, not even an empty one works
+	for _, p := range []string{"", "pass123"} {
+		if PasswordCheck(context.Background(), p, dummyHash) == nil {
+			t.Errorf("❌ The dummy hash matched %q", p)
+		}
+	}
+
+	t.Log("✅ The dummy check always fails.")
+}